@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/go-logr/logr"
+	"github.com/mproffitt/crossbuilder/pkg/generate/composition/build"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	xrdGenerator    = "xrd-gen"
+	artifactsConfig = "artifacts:config="
+)
+
+// checkXRDs runs every xrd-gen go:generate directive with its artifacts
+// written to a temporary directory and compares them with the artifact
+// directory on disk. The deepcopy generator is skipped as it writes into the
+// source tree.
+func checkXRDs(log logr.Logger) ([]build.FileDiff, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting current working directory")
+	}
+
+	paths, err := filePathWalkDir(".", "generate.go")
+	if err != nil {
+		return nil, errors.Wrap(err, "error walking directory")
+	}
+
+	var diffs []build.FileDiff
+	for _, path := range paths {
+		directives, err := xrdGenDirectives(filepath.Join(path, "generate.go"), log)
+		if err != nil {
+			return nil, err
+		}
+		for _, directive := range directives {
+			d, err := checkXRDDirective(path, directive, cwd, log)
+			if err != nil {
+				return nil, err
+			}
+			diffs = append(diffs, d...)
+		}
+	}
+	return diffs, nil
+}
+
+// xrdGenDirective is a go:generate directive running xrd-gen.
+type xrdGenDirective struct {
+	// command runs xrd-gen, for example go run with the path of its
+	// package. It is empty if the directive runs the xrd-gen binary.
+	command []string
+
+	// args are the arguments passed to xrd-gen.
+	args []string
+}
+
+// goRunFlagsWithValue are the go run flags taking a separate value.
+var goRunFlagsWithValue = map[string]bool{
+	"-C": true, "-asmflags": true, "-exec": true, "-gcflags": true, "-ldflags": true,
+	"-mod": true, "-modfile": true, "-overlay": true, "-p": true, "-pkgdir": true,
+	"-tags": true, "-toolexec": true,
+}
+
+// xrdGenDirectives returns all go:generate directives in the given file
+// running xrd-gen, either as binary or with go run. Other directives are
+// logged and skipped.
+func xrdGenDirectives(file string, log logr.Logger) ([]xrdGenDirective, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %q", file)
+	}
+	defer f.Close() // nolint:errcheck
+
+	var directives []xrdGenDirective
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "//go:generate" {
+			continue
+		}
+		if d, ok := parseXRDGenDirective(fields[1:]); ok {
+			directives = append(directives, d)
+			continue
+		}
+		log.Info("skipping go:generate directive not running xrd-gen", "file", file, "directive", strings.Join(fields[1:], " "))
+	}
+	return directives, errors.Wrapf(scanner.Err(), "error reading %q", file)
+}
+
+// parseXRDGenDirective parses the command of a go:generate directive and
+// returns false if it does not run xrd-gen.
+func parseXRDGenDirective(fields []string) (xrdGenDirective, bool) {
+	if fields[0] == xrdGenerator {
+		return xrdGenDirective{args: fields[1:]}, true
+	}
+	if len(fields) < 3 || fields[0] != "go" || fields[1] != "run" {
+		return xrdGenDirective{}, false
+	}
+
+	i := 2
+	for i < len(fields) && strings.HasPrefix(fields[i], "-") {
+		if goRunFlagsWithValue[fields[i]] {
+			i++
+		}
+		i++
+	}
+	if i >= len(fields) {
+		return xrdGenDirective{}, false
+	}
+	pkg, _, _ := strings.Cut(fields[i], "@")
+	if path.Base(pkg) != xrdGenerator {
+		return xrdGenDirective{}, false
+	}
+	return xrdGenDirective{command: fields[:i+1], args: fields[i+1:]}, true
+}
+
+// checkXRDDirective runs the given xrd-gen directive in the given directory
+// and compares the artifacts it generates with those on disk.
+func checkXRDDirective(path string, directive xrdGenDirective, cwd string, log logr.Logger) ([]build.FileDiff, error) {
+	tmp, err := os.MkdirTemp("", "xrc-gen-check-")
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating temporary directory")
+	}
+	defer os.RemoveAll(tmp) // nolint:errcheck
+
+	var artifacts string
+	checkArgs := make([]string, 0, len(directive.args))
+	for _, arg := range directive.args {
+		switch {
+		case arg == "object" || strings.HasPrefix(arg, "object:"):
+			continue
+		case strings.HasPrefix(arg, "output:") && strings.Contains(arg, artifactsConfig):
+			start := strings.Index(arg, artifactsConfig) + len(artifactsConfig)
+			end := len(arg)
+			if i := strings.Index(arg[start:], ","); i >= 0 {
+				end = start + i
+			}
+			artifacts = filepath.Join(path, arg[start:end])
+			arg = arg[:start] + tmp + arg[end:]
+		}
+		checkArgs = append(checkArgs, arg)
+	}
+	if artifacts == "" {
+		log.Info("xrd-gen writes no artifacts, skipping check", "path", path)
+		return nil, nil
+	}
+
+	bin := xrdGenerator
+	if p := filepath.Join(cwd, "crossbuilder", "bin", xrdGenerator); fileExists(p) {
+		bin = p
+	}
+	if len(directive.command) > 0 {
+		bin = directive.command[0]
+		checkArgs = append(append([]string{}, directive.command[1:]...), checkArgs...)
+	}
+	if err := runCommand(bin, checkArgs, nil, path, log); err != nil {
+		return nil, err
+	}
+
+	return diffArtifacts(tmp, artifacts)
+}
+
+// diffArtifacts compares the artifacts generated into the given directory
+// with those in the artifact directory. The artifact directory may be shared
+// with other files, such as compositions or crossplane.yaml, so a file there
+// that was not generated is only reported as deleted if it holds an object
+// of a kind xrd-gen generates.
+func diffArtifacts(generated, artifacts string) ([]build.FileDiff, error) {
+	files, err := readFiles(generated, artifacts)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := readFiles(artifacts, artifacts)
+	if err != nil {
+		return nil, err
+	}
+	for p, b := range existing {
+		if _, ok := files[p]; !ok && isXRDGenArtifact(b) {
+			files[p] = nil // no longer generated
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for p := range files {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+
+	var diffs []build.FileDiff
+	for _, p := range names {
+		d, err := build.DiffFile(p, files[p])
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			diffs = append(diffs, *d)
+		}
+	}
+	return diffs, nil
+}
+
+// xrdGenKinds are the kinds of the objects xrd-gen writes as artifacts.
+var xrdGenKinds = map[schema.GroupKind]bool{
+	xapiextv1.CompositeResourceDefinitionGroupVersionKind.GroupKind(): true,
+	build.CustomResourceDefinitionGroupVersionKind.GroupKind():        true,
+}
+
+// isXRDGenArtifact returns true if the given YAML holds an object of a kind
+// xrd-gen generates. YAML that cannot be decoded is not an artifact.
+func isXRDGenArtifact(b []byte) bool {
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(b), 4096)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return false
+		}
+		var tm metav1.TypeMeta
+		if err := json.Unmarshal(raw, &tm); err != nil {
+			continue // not an object
+		}
+		if xrdGenKinds[tm.GroupVersionKind().GroupKind()] {
+			return true
+		}
+	}
+}
+
+// readFiles returns the content of the YAML files in the given directory by
+// their path relative to it, joined with base. A directory that does not
+// exist holds no files.
+func readFiles(dir, base string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".yaml" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[filepath.Join(base, rel)] = b
+		return nil
+	})
+	return files, errors.Wrapf(err, "error reading %q", dir)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
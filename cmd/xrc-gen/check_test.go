@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiffArtifacts(t *testing.T) {
+	xrd := func(name string) string {
+		return "apiVersion: apiextensions.crossplane.io/v1\nkind: CompositeResourceDefinition\nmetadata:\n  name: " + name + "\n"
+	}
+	composition := "apiVersion: apiextensions.crossplane.io/v1\nkind: Composition\nmetadata:\n  name: a\n"
+	configuration := "apiVersion: meta.pkg.crossplane.io/v1\nkind: Configuration\nmetadata:\n  name: a\n"
+	crd := "apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: a\n"
+
+	cases := map[string]struct {
+		generated   map[string]string
+		artifacts   map[string]string
+		wantChanged []string
+		wantDeleted []string
+	}{
+		"UpToDate": {
+			generated: map[string]string{"a.yaml": xrd("a")},
+			artifacts: map[string]string{"a.yaml": xrd("a")},
+		},
+		"Changed": {
+			generated:   map[string]string{"a.yaml": xrd("a")},
+			artifacts:   map[string]string{"a.yaml": xrd("b")},
+			wantChanged: []string{"a.yaml"},
+		},
+		"Created": {
+			generated:   map[string]string{"a.yaml": xrd("a")},
+			wantChanged: []string{"a.yaml"},
+		},
+		"SharedDirectory": {
+			generated: map[string]string{"a.yaml": xrd("a"), "c.yaml": xrd("c")},
+			artifacts: map[string]string{
+				"a.yaml":                       xrd("a"),
+				"b.yaml":                       "---\n" + xrd("b"),
+				"crd.yaml":                     crd,
+				"crossplane.yaml":              configuration,
+				"xa/composition.yaml":          composition,
+				"xa/composition-template.yaml": "{{ .Values }}\nkey: [unclosed\n",
+			},
+			wantChanged: []string{"b.yaml", "c.yaml", "crd.yaml"},
+			wantDeleted: []string{"b.yaml", "crd.yaml"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			generated := filepath.Join(root, "generated")
+			artifacts := filepath.Join(root, "apis")
+			writeFiles(t, generated, tc.generated)
+			writeFiles(t, artifacts, tc.artifacts)
+
+			diffs, err := diffArtifacts(generated, artifacts)
+			if err != nil {
+				t.Fatalf("diffArtifacts(): %v", err)
+			}
+			var changed, deleted []string
+			for _, d := range diffs {
+				rel, err := filepath.Rel(artifacts, d.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				changed = append(changed, filepath.ToSlash(rel))
+				if d.Deleted {
+					deleted = append(deleted, filepath.ToSlash(rel))
+				}
+			}
+			if !reflect.DeepEqual(changed, tc.wantChanged) {
+				t.Errorf("diffArtifacts() changed %q, want %q", changed, tc.wantChanged)
+			}
+			if !reflect.DeepEqual(deleted, tc.wantDeleted) {
+				t.Errorf("diffArtifacts() deleted %q, want %q", deleted, tc.wantDeleted)
+			}
+		})
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for f, content := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"flag"
 	"fmt"
 	"io"
 	"io/fs"
@@ -11,6 +12,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"plugin"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -24,7 +27,12 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
-const poolSize = 10
+const (
+	poolSize = 10
+
+	// outputDir is the directory compositions are written to.
+	outputDir = "apis"
+)
 
 type pathchan struct {
 	p string // path
@@ -37,7 +45,27 @@ type plug struct {
 	plugins     chan pathchan
 }
 
-var packages []build.CompositionBuilder
+// modeValue is a file mode flag given in octal.
+type modeValue fs.FileMode
+
+func (m *modeValue) String() string {
+	return fmt.Sprintf("%#o", uint32(*m))
+}
+
+func (m *modeValue) Set(s string) error {
+	v, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return err
+	}
+	*m = modeValue(v)
+	return nil
+}
+
+var (
+	packages []build.CompositionBuilder
+	sources  []string
+	hooks    []build.Hook
+)
 
 func setupPool(plugins chan plug, wg *sync.WaitGroup, log logr.Logger) []chan bool {
 	var stop []chan bool = make([]chan bool, poolSize)
@@ -57,7 +85,10 @@ func setupPool(plugins chan plug, wg *sync.WaitGroup, log logr.Logger) []chan bo
 	return stop
 }
 
-func compilePlugins(log logr.Logger) []string {
+// compilePlugins compiles the compositions found below the current directory
+// and returns the paths of the plugins that compiled. An error is returned if
+// any of them failed to compile.
+func compilePlugins(log logr.Logger) ([]string, error) {
 	var (
 		err   error
 		paths []string
@@ -66,8 +97,7 @@ func compilePlugins(log logr.Logger) []string {
 
 	paths, err = filePathWalkDir(".", "main.go")
 	if err != nil {
-		log.Error(err, "error walking directory")
-		return nil
+		return nil, errors.Wrap(err, "error walking directory")
 	}
 
 	for _, path := range paths {
@@ -109,10 +139,14 @@ func compilePlugins(log logr.Logger) []string {
 
 	pluginPaths := make([]string, 0)
 
-	var i int = 1
+	var (
+		i      int = 1
+		failed int
+	)
 	for plugin := range pchan {
 		if plugin.e != nil {
-			log.Info("error compiling", "plugin", plugin.e)
+			log.Error(plugin.e, "error compiling", "plugin", plugin.p)
+			failed++
 		} else {
 			pluginPaths = append(pluginPaths, plugin.p)
 			log.Info(fmt.Sprintf("(%d of %d)", i, len(paths)), "compiled plugin", plugin.p)
@@ -131,7 +165,10 @@ func compilePlugins(log logr.Logger) []string {
 	}
 
 	log.Info("compiled all plugins")
-	return pluginPaths
+	if failed > 0 {
+		return pluginPaths, errors.Errorf("%d of %d plugins failed to compile", failed, len(paths))
+	}
+	return pluginPaths, nil
 }
 
 // Dynamically compile the plugin
@@ -183,12 +220,16 @@ func compile(path, composition string, wg *sync.WaitGroup, plugins chan pathchan
 }
 
 func runCmd(args, env []string, wd string, log logr.Logger) error {
+	return runCommand("go", args, env, wd, log)
+}
+
+func runCommand(name string, args, env []string, wd string, log logr.Logger) error {
 	// MAX wait time for a build to complete is 5 minutes
 	var duration time.Duration = 300 * time.Second
 	ctx, cancel := context.WithTimeout(context.Background(), duration)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd := exec.CommandContext(ctx, name, args...)
 	cmd.Dir = wd
 	cmd.Env = os.Environ()
 	cmd.Env = append(cmd.Env, env...)
@@ -239,7 +280,22 @@ func loadPlugin(path string) (err error) {
 		return
 	}
 
+	// Plugins may export shared hooks, a builder or both.
+	hooksSym, hooksErr := plug.Lookup("Hooks")
+	if hooksErr == nil {
+		h, isHooks := hooksSym.(*[]build.Hook)
+		if !isHooks {
+			err = errors.New("unexpected type from module symbol - 'Hooks' must be of type '[]build.Hook'")
+			return
+		}
+		hooks = append(hooks, *h...)
+	}
+
 	if sym, err = plug.Lookup("Builder"); err != nil {
+		if hooksErr == nil {
+			err = nil // hooks only plugin
+			return
+		}
 		err = errors.Wrap(err, fmt.Sprintf("error loading symbol 'Builder' from plugin %q", path))
 		return
 	}
@@ -250,6 +306,7 @@ func loadPlugin(path string) (err error) {
 	}
 
 	packages = append(packages, builder)
+	sources = append(sources, path)
 	return
 }
 
@@ -323,32 +380,216 @@ func runGenerators(log logr.Logger) {
 	}
 }
 
+// pruneCompositions deletes the compositions the given writer generated in a
+// previous run but not in this one, or only lists them on a dry run.
+func pruneCompositions(w build.DirectoryWriter, dryRun bool, log logr.Logger) error {
+	stale, err := w.Prune(dryRun)
+	if err != nil {
+		return err
+	}
+	for _, path := range stale {
+		if dryRun {
+			log.Info("would prune", "path", path)
+			continue
+		}
+		log.Info("pruned", "path", path)
+	}
+	return nil
+}
+
 func main() {
+	convertResources := flag.Bool("convert-resources", false,
+		"convert Resources mode compositions to Pipeline mode compositions using function-patch-and-transform")
+	crdsDir := flag.String("crds", "crds",
+		"directory holding the CRDs unstructured composed resources are validated against")
+	output := flag.String("output", "",
+		"write all XRDs and compositions as a single YAML stream to the given file, or to stdout if set to -")
+	xrdsDir := flag.String("xrds", outputDir,
+		"directory holding the generated XRDs")
+	layout := flag.String("layout", build.DefaultLayout,
+		"Go template of the path compositions are written to, relative to "+outputDir)
+	fileMode, dirMode := modeValue(build.DefaultFileMode), modeValue(build.DefaultDirMode)
+	flag.Var(&fileMode, "file-mode", "octal mode of the composition files written")
+	flag.Var(&dirMode, "dir-mode", "octal mode of the directories created")
+	prune := flag.Bool("prune", true,
+		"delete compositions generated by a previous run that are no longer produced")
+	pruneDryRun := flag.Bool("prune-dry-run", false,
+		"list the compositions that would be pruned without deleting them")
+	check := flag.Bool("check", false,
+		"compare the generated XRDs and compositions with the files on disk instead of writing them")
+	abortOnError := flag.Bool("abort-on-error", false,
+		"write no composition at all if any of them fails to build")
+	flag.Parse()
+
 	zl := zap.New(zap.UseDevMode(true), zap.Level(zapcore.Level(-3)))
 	log := zl.WithName("crossbuilder")
 	ctrl.SetLogger(log)
 
-	log.Info("running generators")
-	runGenerators(log)
+	var (
+		failed bool
+		diffs  []build.FileDiff
+	)
+	if *check {
+		log.Info("checking generated XRDs")
+		xrdDiffs, err := checkXRDs(log)
+		if err != nil {
+			log.Error(err, "error checking generated XRDs")
+			failed = true
+		}
+		diffs = append(diffs, xrdDiffs...)
+	} else {
+		log.Info("running generators")
+		runGenerators(log)
+	}
 	log.Info("Compiling plugins")
 
-	var paths []string = compilePlugins(log)
+	paths, err := compilePlugins(log)
+	if err != nil {
+		log.Error(err, "error compiling plugins")
+		failed = true
+	}
+	// Plugins are compiled concurrently, load them in a stable order so
+	// the compositions are built in the same order on every run.
+	sort.Strings(paths)
 	for _, path := range paths {
 		log.Info("loading", "plugin", path)
 		if err := loadPlugin(path); err != nil {
 			log.Error(err, "error loading plugin", "path", path)
+			failed = true
 			continue
 		}
 	}
 
+	xrds, err := build.LoadCompositeResourceDefinitions(*xrdsDir)
+	if err != nil {
+		log.Error(err, "error loading composite resource definitions", "path", *xrdsDir)
+		os.Exit(1)
+	}
+	crds, err := build.LoadCRDs(*crdsDir)
+	if err != nil {
+		log.Error(err, "error loading custom resource definitions", "path", *crdsDir)
+		os.Exit(1)
+	}
+
+	writerConfig := build.DirectoryWriterConfig{
+		Dir:      outputDir,
+		Layout:   *layout,
+		FileMode: fs.FileMode(fileMode),
+		DirMode:  fs.FileMode(dirMode),
+	}
+	var (
+		writer      build.CompositionWriter
+		checkWriter build.CheckWriter
+		dirWriter   build.DirectoryWriter
+		outFile     *os.File
+	)
+	switch {
+	case *check:
+		if checkWriter, err = build.NewCheckWriter(writerConfig); err != nil {
+			log.Error(err, "error creating composition writer")
+			os.Exit(1)
+		}
+		writer = checkWriter
+	case *output != "":
+		out := os.Stdout
+		if *output != "-" {
+			if out, err = os.Create(*output); err != nil {
+				log.Error(err, "error creating output file", "path", *output)
+				os.Exit(1)
+			}
+			outFile = out
+		}
+		stream := build.NewStreamWriter(out)
+		for _, xrd := range xrds {
+			if err := stream.WriteXRD(xrd); err != nil {
+				log.Error(err, "error writing composite resource definition", "name", xrd.GetName())
+				failed = true
+			}
+		}
+		writer = stream
+	default:
+		if dirWriter, err = build.NewDirectoryWriterFromConfig(writerConfig); err != nil {
+			log.Error(err, "error creating composition writer")
+			os.Exit(1)
+		}
+		writer = dirWriter
+	}
+
 	runner := build.NewRunner(
 		build.RunnerConfig{
-			Writer:  build.NewDirectoryWriter("apis"),
-			Builder: packages,
+			Writer:                       writer,
+			Builder:                      packages,
+			CompositeResourceDefinitions: xrds,
+			ConvertResourcesToPipeline:   *convertResources,
+			CustomResourceDefinitions:    crds,
+			Sources:                      sources,
+			AbortOnError:                 *abortOnError,
+			Hooks:                        hooks,
 		},
 	)
 
 	if err := runner.Build(); err != nil {
-		log.Error(err, "error building compositions")
+		failed = true
+		buildErrs, ok := err.(*build.BuildErrors)
+		if !ok {
+			log.Error(err, "error building compositions")
+		} else {
+			for _, e := range buildErrs.Errors {
+				log.Error(e.Err, "error building composition",
+					"name", e.Name, "composite", e.CompositeTypeRef.String(), "plugin", e.Source)
+			}
+			if len(buildErrs.Errors) > 0 {
+				log.Info(fmt.Sprintf("%d of %d compositions failed", len(buildErrs.Errors), buildErrs.Total))
+			}
+			if buildErrs.Err != nil {
+				log.Error(buildErrs.Err, "error writing compositions")
+			}
+		}
+	}
+
+	// The output file is closed explicitly as os.Exit skips deferred calls
+	// and the stream may only be written completely when it is closed.
+	if outFile != nil {
+		if err := outFile.Close(); err != nil {
+			log.Error(err, "error closing output file", "path", *output)
+			failed = true
+		}
+	}
+
+	if dirWriter != nil && (*prune || *pruneDryRun) {
+		if failed {
+			log.Info("not pruning compositions as not all of them were built")
+		} else if err := pruneCompositions(dirWriter, *pruneDryRun, log); err != nil {
+			log.Error(err, "error pruning compositions")
+			failed = true
+		}
+	}
+
+	if checkWriter != nil {
+		compositionDiffs, err := checkWriter.Diff()
+		if err != nil {
+			log.Error(err, "error checking generated compositions")
+			failed = true
+		}
+		for _, d := range compositionDiffs {
+			// The composition of a file that would be deleted, or be
+			// removed from the manifest, may just have failed to build.
+			if failed && (d.Deleted || filepath.Base(d.Path) == build.ManifestFile) {
+				log.Info("not reporting removed compositions as not all compositions were built", "path", d.Path)
+				continue
+			}
+			diffs = append(diffs, d)
+		}
+	}
+	for _, d := range diffs {
+		fmt.Print(d.Diff)
+	}
+	if len(diffs) > 0 {
+		log.Info(fmt.Sprintf("%d generated files are out of date", len(diffs)))
+		failed = true
+	}
+
+	if failed {
+		os.Exit(1)
 	}
 }
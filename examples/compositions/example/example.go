@@ -1,8 +1,6 @@
 package main
 
 import (
-	"reflect"
-
 	xv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 
@@ -27,7 +25,6 @@ func (b *builder) Build(c build.CompositionSkeleton) {
 
 	c.
 		NewResource(build.ObjectKindReference{
-			GroupVersionKind: rbacv1.SchemeGroupVersion.WithKind(reflect.TypeOf(rbacv1.ClusterRole{}).Name()),
 			Object: &rbacv1.ClusterRole{
 				Rules: []rbacv1.PolicyRule{
 					{
@@ -3,11 +3,10 @@ package main
 import (
 	"github.com/mproffitt/crossbuilder/examples/apis/v1alpha1"
 
-	xgt "github.com/crossplane-contrib/function-go-templating/input/v1beta1"
 	xpt "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
 	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
 	"github.com/mproffitt/crossbuilder/pkg/generate/composition/build"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -15,10 +14,16 @@ type builder struct{}
 
 var Builder = builder{}
 
+func init() {
+	// Register the example APIs so their GroupVersionKind can be inferred.
+	if err := build.AddToScheme(v1alpha1.AddToScheme); err != nil {
+		panic(err)
+	}
+}
+
 func (b *builder) GetCompositeTypeRef() build.ObjectKindReference {
 	return build.ObjectKindReference{
-		GroupVersionKind: v1alpha1.XExampleGroupVersionKind,
-		Object:           &xgt.GoTemplate{},
+		Object: &v1alpha1.XExample{},
 	}
 }
 
@@ -40,27 +45,30 @@ func (b *builder) Build(c build.CompositionSkeleton) {
 	}
 
 	c.NewPipelineStep("test-step").
-		WithFunctionRef(xapiextv1.FunctionReference{
-			Name: "function-go-templating",
-		}).
-		WithInput(build.ObjectKindReference{
-			Object: &xgt.GoTemplate{
-				TypeMeta: metav1.TypeMeta{
-					APIVersion: "gotemplating.fn.crossplane.io/v1beta1",
-					Kind:       "GoTemplate",
+		WithFunction(build.GoTemplating().
+			WithInline(template))
+	c.NewPipelineStep("test-step-2").
+		WithFunction(build.PatchAndTransform().
+			WithResources(
+				xpt.ComposedTemplate{
+					Name: "resource-1",
+					Base: &runtime.RawExtension{
+						Object: &corev1.Pod{},
+					},
 				},
-				Source: xgt.InlineSource,
-				Inline: &xgt.TemplateSourceInline{
-					Template: template,
+				xpt.ComposedTemplate{
+					Name: "resource-2",
+					Base: &runtime.RawExtension{
+						Object: &v1alpha1.XExample{},
+					},
 				},
-			},
-		}).
+			)).
 		WithPatches(
 			"resource-1",
 			xpt.ComposedPatch{
 				Type: xpt.PatchTypeFromCompositeFieldPath,
 				Patch: xpt.Patch{
-					FromFieldPath: strPtr("spec.containers[0].image"),
+					FromFieldPath: strPtr("spec.parameters.exampleField"),
 					ToFieldPath:   strPtr("spec.containers[0].image"),
 				},
 			},
@@ -71,41 +79,21 @@ func (b *builder) Build(c build.CompositionSkeleton) {
 					ToFieldPath:   strPtr("metadata.labels[app]"),
 				},
 			},
-		)
-	c.NewPipelineStep("test-step-2").
-		WithFunctionRef(xapiextv1.FunctionReference{
-			Name: "function-patch-and-transform",
-		}).
-		WithInput(build.ObjectKindReference{
-			Object: &xpt.Resources{
-				TypeMeta: metav1.TypeMeta{
-					APIVersion: "pt.crossplane.io/v1beta1",
-					Kind:       "Resources",
-				},
-				Resources: []xpt.ComposedTemplate{
-					{
-						Name: "resource-2",
-						Base: &runtime.RawExtension{
-							Object: &v1alpha1.XExample{},
-						},
-					},
-				},
-			},
-		}).
+		).
 		WithPatches(
 			"resource-2",
 			xpt.ComposedPatch{
 				Type: xpt.PatchTypeFromCompositeFieldPath,
 				Patch: xpt.Patch{
-					FromFieldPath: strPtr("status.atProvider.something"),
-					ToFieldPath:   strPtr("spec.forProvider.something"),
+					FromFieldPath: strPtr("spec.parameters.exampleField"),
+					ToFieldPath:   strPtr("spec.parameters.exampleField"),
 				},
 			},
 			xpt.ComposedPatch{
 				Type: xpt.PatchTypeFromCompositeFieldPath,
 				Patch: xpt.Patch{
 					FromFieldPath: strPtr("metadata.labels[app]"),
-					ToFieldPath:   strPtr("spec.forProvider.labels[app]"),
+					ToFieldPath:   strPtr("metadata.labels[app]"),
 				},
 			},
 		)
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+
+	"github.com/mproffitt/crossbuilder/pkg/generate/composition/build"
+)
+
+// Hooks are run by xrc-gen on every composition in the repository.
+var Hooks = []build.Hook{
+	{
+		// Builders may patch from the team label of the composite, which
+		// is not part of its Go type.
+		Name: "register-team-label",
+		Skeleton: func(_ build.ObjectKindReference, c build.CompositionSkeleton) error {
+			c.RegisterCompositeLabels("example.io/team")
+			return nil
+		},
+	},
+	build.LabelsHook(map[string]string{
+		"example.io/team": "platform",
+	}),
+	// Appended after the steps of each builder. Steps the builders pinned
+	// with AlwaysLast still run after it.
+	build.StepHook("auto-ready", func(s build.PipelineStepSkeleton) {
+		s.WithFunction(build.AutoReady())
+	}),
+}
+
+func init() {
+	// The commit is only annotated when set, as `xrc-gen -check` would
+	// otherwise report every composition as out of date after each commit.
+	if sha := os.Getenv("GIT_SHA"); sha != "" {
+		Hooks = append(Hooks, build.AnnotationsHook(map[string]string{
+			"example.io/git-sha": sha,
+		}))
+	}
+}
@@ -1,13 +1,21 @@
 package build
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
 	"github.com/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 const (
-	errWriteComposition    = "failed to write composition"
-	errFmtBuildComposition = "failed to build composition at index %d"
+	errWriteComposition = "failed to write composition"
+	errFlushWriter      = "failed to flush composition writer"
+	errFmtBuilderPanic  = "panic while building composition: %v"
+	errFmtBuildErrors   = "%d of %d compositions failed"
 )
 
 // CompositionBuilder specifies the interface for user defined type that is
@@ -25,6 +33,106 @@ type CompositionBuilder interface {
 type RunnerConfig struct {
 	Builder []CompositionBuilder
 	Writer  CompositionWriter
+
+	// CompositeResourceDefinitions are the XRDs of the composite types.
+	// Connection details of a composition are checked against the
+	// connectionSecretKeys of the XRD defining its composite.
+	CompositeResourceDefinitions []xapiextv1.CompositeResourceDefinition
+
+	// ConvertResourcesToPipeline converts Resources mode compositions into
+	// Pipeline mode compositions composing the same resources with
+	// function-patch-and-transform, followed by function-auto-ready.
+	ConvertResourcesToPipeline bool
+
+	// CustomResourceDefinitions are the CRDs of composed resources. Bases
+	// without Go type, such as unstructured objects, and the field paths
+	// referring to them are validated against the schema of their CRD.
+	CustomResourceDefinitions []extv1.CustomResourceDefinition
+
+	// Sources are the paths of the plugins the builders were loaded from,
+	// by index of the builder. They are reported in build errors.
+	Sources []string
+
+	// AbortOnError writes no composition at all if any of them fails to
+	// build. By default the compositions that were built successfully are
+	// written anyway.
+	AbortOnError bool
+
+	// Hooks are run in order on every composition, see Hook.
+	Hooks []Hook
+}
+
+// BuildError is the error building or writing a single composition.
+type BuildError struct {
+	// Index is the index of the builder in RunnerConfig.Builder.
+	Index int
+
+	// Name is the name of the composition, if the builder set one.
+	Name string
+
+	// CompositeTypeRef is the composite type of the composition.
+	CompositeTypeRef schema.GroupVersionKind
+
+	// Source is the path of the plugin the builder was loaded from, if
+	// known.
+	Source string
+
+	// Err is the error that occurred.
+	Err error
+}
+
+func (e *BuildError) Error() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "composition at index %d", e.Index)
+	if e.Name != "" {
+		fmt.Fprintf(b, " named %q", e.Name)
+	}
+	if !e.CompositeTypeRef.Empty() {
+		fmt.Fprintf(b, " for %s", e.CompositeTypeRef)
+	}
+	if e.Source != "" {
+		fmt.Fprintf(b, " from %s", e.Source)
+	}
+	fmt.Fprintf(b, ": %s", e.Err)
+	return b.String()
+}
+
+// Cause returns the underlying error.
+func (e *BuildError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the underlying error.
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
+// BuildErrors are the errors of all compositions that failed to build or to
+// be written by a CompositionBuildRunner.
+type BuildErrors struct {
+	// Errors holds an error for each composition that failed.
+	Errors []*BuildError
+
+	// Total is the number of compositions that were built.
+	Total int
+
+	// Err is an error that is not specific to a composition, such as failing
+	// to flush the writer.
+	Err error
+}
+
+func (e *BuildErrors) Error() string {
+	lines := make([]string, 0, len(e.Errors)+2)
+	if len(e.Errors) > 0 || e.Err == nil {
+		lines = append(lines, fmt.Sprintf(errFmtBuildErrors, len(e.Errors), e.Total))
+	}
+	for _, err := range e.Errors {
+		lines = append(lines, "- "+err.Error())
+	}
+	if e.Err != nil {
+		lines = append(lines, e.Err.Error())
+	}
+	return strings.Join(lines, "\n")
 }
 
 // CompositionBuildRunner specifies the interface for a composition builder.
@@ -45,28 +153,126 @@ type compositionBuildRunner struct {
 	config RunnerConfig
 }
 
+// builtComposition is a composition and the index of its builder.
+type builtComposition struct {
+	index       int
+	composition xapiextv1.Composition
+}
+
 // Build generates all compositions from the builders and sends them to the
-// output writer.
+// output writer. All builders are run even if some of them fail, and the
+// errors of all failed compositions are returned as *BuildErrors, together
+// with the error flushing the writer, if any.
 func (b *compositionBuildRunner) Build() error {
-	compositions := make([]xapiextv1.Composition, len(b.config.Builder))
+	if err := validateXRDNames(b.config.CompositeResourceDefinitions); err != nil {
+		return err
+	}
+
+	compositions := make([]builtComposition, 0, len(b.config.Builder))
+	buildErrs := &BuildErrors{Total: len(b.config.Builder)}
 	for i, builder := range b.config.Builder {
-		compSkeleton := &compositionSkeleton{
-			composite: builder.GetCompositeTypeRef(),
+		comp, err := b.build(i, builder)
+		if err != nil {
+			buildErrs.Errors = append(buildErrs.Errors, err)
+			continue
 		}
+		compositions = append(compositions, builtComposition{index: i, composition: comp})
+	}
 
-		builder.Build(compSkeleton)
+	compositions, conflicts := b.removeConflicts(compositions)
+	buildErrs.Errors = append(buildErrs.Errors, conflicts...)
 
-		comp, err := compSkeleton.ToComposition()
-		if err != nil {
-			return errors.Wrapf(err, errFmtBuildComposition, i)
+	if len(buildErrs.Errors) == 0 || !b.config.AbortOnError {
+		for _, c := range compositions {
+			if err := b.config.Writer.Write(c.composition); err != nil {
+				buildErrs.Errors = append(buildErrs.Errors, b.compositionError(c, errors.Wrap(err, errWriteComposition)))
+			}
+		}
+		if f, ok := b.config.Writer.(flusher); ok {
+			if err := f.Flush(); err != nil {
+				buildErrs.Err = errors.Wrap(err, errFlushWriter)
+			}
 		}
-		compositions[i] = comp
 	}
 
-	for _, comp := range compositions {
-		if err := b.config.Writer.Write(comp); err != nil {
-			return errors.Wrap(err, errWriteComposition)
-		}
+	if len(buildErrs.Errors) > 0 || buildErrs.Err != nil {
+		sort.SliceStable(buildErrs.Errors, func(i, j int) bool {
+			return buildErrs.Errors[i].Index < buildErrs.Errors[j].Index
+		})
+		return buildErrs
 	}
 	return nil
 }
+
+// compositionError returns a BuildError for the given composition.
+func (b *compositionBuildRunner) compositionError(c builtComposition, err error) *BuildError {
+	ref := c.composition.Spec.CompositeTypeRef
+	return &BuildError{
+		Index:            c.index,
+		Name:             c.composition.GetName(),
+		CompositeTypeRef: schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind),
+		Source:           b.source(c.index),
+		Err:              err,
+	}
+}
+
+// build runs the builder at the given index and returns its composition.
+// Panics of the builder and hooks are recovered and returned as error.
+func (b *compositionBuildRunner) build(index int, builder CompositionBuilder) (comp xapiextv1.Composition, buildErr *BuildError) {
+	compSkeleton := &compositionSkeleton{
+		convertToPipeline: b.config.ConvertResourcesToPipeline,
+		crds:              b.config.CustomResourceDefinitions,
+	}
+	fail := func(err error) *BuildError {
+		composite := compSkeleton.composite
+		_ = composite.resolve() // best effort, the kind is only reported
+		return &BuildError{
+			Index:            index,
+			Name:             compSkeleton.name,
+			CompositeTypeRef: composite.GroupVersionKind,
+			Source:           b.source(index),
+			Err:              err,
+		}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			comp, buildErr = xapiextv1.Composition{}, fail(errors.Errorf(errFmtBuilderPanic, r))
+		}
+	}()
+
+	compSkeleton.composite = builder.GetCompositeTypeRef()
+	_ = compSkeleton.composite.resolve() // errors are returned by ToComposition
+	compSkeleton.xrd = compositeResourceDefinitionFor(b.config.CompositeResourceDefinitions, compSkeleton.composite.GroupVersionKind)
+	builder.Build(compSkeleton)
+	for i, h := range b.config.Hooks {
+		if h.Skeleton == nil {
+			continue
+		}
+		if err := h.Skeleton(compSkeleton.composite, compSkeleton); err != nil {
+			return xapiextv1.Composition{}, fail(errors.Wrapf(err, errFmtRunHook, h.describe(i)))
+		}
+	}
+
+	comp, err := compSkeleton.ToComposition()
+	if err != nil {
+		return xapiextv1.Composition{}, fail(err)
+	}
+	for i, h := range b.config.Hooks {
+		if h.Composition == nil {
+			continue
+		}
+		if err := h.Composition(&comp); err != nil {
+			return xapiextv1.Composition{}, fail(errors.Wrapf(err, errFmtRunHook, h.describe(i)))
+		}
+	}
+	return comp, nil
+}
+
+// source returns the path of the plugin the builder at the given index was
+// loaded from, if known.
+func (b *compositionBuildRunner) source(index int) string {
+	if index < len(b.config.Sources) {
+		return b.config.Sources[index]
+	}
+	return ""
+}
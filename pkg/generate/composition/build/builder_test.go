@@ -0,0 +1,252 @@
+package build
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	xpt "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/mproffitt/crossbuilder/examples/apis/v1alpha1"
+)
+
+// testBuilder builds a composition for the given composite type by calling
+// build.
+type testBuilder struct {
+	composite ObjectKindReference
+	build     func(c CompositionSkeleton)
+}
+
+func (b *testBuilder) GetCompositeTypeRef() ObjectKindReference {
+	return b.composite
+}
+
+func (b *testBuilder) Build(c CompositionSkeleton) {
+	b.build(c)
+}
+
+// recordingWriter records the compositions written and their names.
+type recordingWriter struct {
+	names        []string
+	compositions []xapiextv1.Composition
+}
+
+func (w *recordingWriter) Write(c xapiextv1.Composition) error {
+	w.names = append(w.names, c.GetName())
+	w.compositions = append(w.compositions, c)
+	return nil
+}
+
+// failingFlushWriter records the compositions written and fails to flush
+// them.
+type failingFlushWriter struct {
+	recordingWriter
+}
+
+func (w *failingFlushWriter) Flush() error {
+	return errors.New("disk full")
+}
+
+// buildNamed returns a build function creating a valid composition with
+// the given name.
+func buildNamed(name string) func(c CompositionSkeleton) {
+	return func(c CompositionSkeleton) {
+		c.WithName(name).WithMode(xapiextv1.CompositionModePipeline)
+		c.NewPipelineStep("patch-and-transform").
+			WithFunction(PatchAndTransform().
+				WithResources(xpt.ComposedTemplate{
+					Name: "resource",
+					Base: &runtime.RawExtension{Object: &v1alpha1.XExample{}},
+				}))
+	}
+}
+
+func TestBuild(t *testing.T) {
+	if err := AddToScheme(v1alpha1.AddToScheme); err != nil {
+		t.Fatal(err)
+	}
+	builder := func(build func(c CompositionSkeleton)) CompositionBuilder {
+		return &testBuilder{composite: ObjectKindReference{Object: &v1alpha1.XExample{}}, build: build}
+	}
+	failing := []CompositionBuilder{
+		builder(buildNamed("a")),
+		builder(func(c CompositionSkeleton) {
+			c.WithName("b")
+			panic("boom")
+		}),
+		builder(func(c CompositionSkeleton) {
+			buildNamed("c")(c)
+			c.GetStep("missing").WithFunction(AutoReady())
+		}),
+		builder(buildNamed("d")),
+	}
+
+	cases := map[string]struct {
+		builders     []CompositionBuilder
+		abortOnError bool
+		flushFails   bool
+		wantWritten  []string
+		wantFailed   []int
+		wantErr      []string
+	}{
+		"AllBuilt": {
+			builders:    []CompositionBuilder{builder(buildNamed("a")), builder(buildNamed("b"))},
+			wantWritten: []string{"a", "b"},
+		},
+		"FailuresAggregated": {
+			builders:    failing,
+			wantWritten: []string{"a", "d"},
+			wantFailed:  []int{1, 2},
+			wantErr: []string{
+				"2 of 4 compositions failed",
+				`- composition at index 1 named "b" for ` + v1alpha1.XExampleGroupVersionKind.String() + " from b.so: panic while building composition: boom",
+				`- composition at index 2 named "c" for ` + v1alpha1.XExampleGroupVersionKind.String() + ` from c.so: no pipeline step named "missing"`,
+			},
+		},
+		"AbortOnError": {
+			builders:     failing,
+			abortOnError: true,
+			wantFailed:   []int{1, 2},
+			wantErr:      []string{"2 of 4 compositions failed"},
+		},
+		"FlushFailed": {
+			builders:    []CompositionBuilder{builder(buildNamed("a"))},
+			flushFails:  true,
+			wantWritten: []string{"a"},
+			wantErr:     []string{"failed to flush composition writer: disk full"},
+		},
+		"FlushFailedAggregated": {
+			builders:    failing,
+			flushFails:  true,
+			wantWritten: []string{"a", "d"},
+			wantFailed:  []int{1, 2},
+			wantErr: []string{
+				"2 of 4 compositions failed",
+				"failed to flush composition writer: disk full",
+			},
+		},
+		"DuplicateNames": {
+			builders:    []CompositionBuilder{builder(buildNamed("a")), builder(buildNamed("b")), builder(buildNamed("a"))},
+			wantWritten: []string{"b"},
+			wantFailed:  []int{0, 2},
+			wantErr:     []string{`composition name "a" is also used by the composition at index 2 from c.so`},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := &recordingWriter{}
+			var writer CompositionWriter = w
+			if tc.flushFails {
+				f := &failingFlushWriter{}
+				w, writer = &f.recordingWriter, f
+			}
+			err := NewRunner(RunnerConfig{
+				Builder:      tc.builders,
+				Writer:       writer,
+				Sources:      []string{"a.so", "b.so", "c.so", "d.so"},
+				AbortOnError: tc.abortOnError,
+			}).Build()
+
+			if !reflect.DeepEqual(w.names, tc.wantWritten) {
+				t.Errorf("Build() wrote %q, want %q", w.names, tc.wantWritten)
+			}
+			if len(tc.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("Build(): %v", err)
+				}
+				return
+			}
+			var buildErrs *BuildErrors
+			if !errors.As(err, &buildErrs) {
+				t.Fatalf("Build() = %v, want *BuildErrors", err)
+			}
+			var failed []int
+			for _, e := range buildErrs.Errors {
+				failed = append(failed, e.Index)
+			}
+			if !reflect.DeepEqual(failed, tc.wantFailed) {
+				t.Errorf("Build() failed compositions = %v, want %v", failed, tc.wantFailed)
+			}
+			for _, want := range tc.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Build() = %v, want error containing %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildConnectionSecretKeys(t *testing.T) {
+	if err := AddToScheme(v1alpha1.AddToScheme); err != nil {
+		t.Fatal(err)
+	}
+	xrd := xapiextv1.CompositeResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "xexamples.test.example.com"},
+		Spec: xapiextv1.CompositeResourceDefinitionSpec{
+			Group:                v1alpha1.XRDGroup,
+			Names:                extv1.CustomResourceDefinitionNames{Kind: v1alpha1.XExampleKind},
+			ConnectionSecretKeys: []string{"username"},
+		},
+	}
+	withKey := func(key string) func(c CompositionSkeleton) {
+		return func(c CompositionSkeleton) {
+			c.WithName("example").WithMode(xapiextv1.CompositionModePipeline)
+			c.NewPipelineStep("patch-and-transform").
+				WithFunction(PatchAndTransform().
+					WithResources(xpt.ComposedTemplate{
+						Name: "resource",
+						Base: &runtime.RawExtension{Object: &v1alpha1.XExample{}},
+						ConnectionDetails: []xpt.ConnectionDetail{{
+							Name:                    key,
+							Type:                    xpt.ConnectionDetailTypeFromConnectionSecretKey,
+							FromConnectionSecretKey: &key,
+						}},
+					}))
+		}
+	}
+
+	cases := map[string]struct {
+		composite ObjectKindReference
+		key       string
+		wantErr   string
+	}{
+		"InferredGVKAdvertisedKey": {
+			composite: ObjectKindReference{Object: &v1alpha1.XExample{}},
+			key:       "username",
+		},
+		"InferredGVKUnadvertisedKey": {
+			composite: ObjectKindReference{Object: &v1alpha1.XExample{}},
+			key:       "password",
+			wantErr:   `connection secret key "password" is not advertised by the XRD xexamples.test.example.com`,
+		},
+		"ExplicitGVKUnadvertisedKey": {
+			composite: ObjectKindReference{
+				GroupVersionKind: v1alpha1.XExampleGroupVersionKind,
+				Object:           &v1alpha1.XExample{},
+			},
+			key:     "password",
+			wantErr: `connection secret key "password" is not advertised by the XRD xexamples.test.example.com`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := NewRunner(RunnerConfig{
+				Builder:                      []CompositionBuilder{&testBuilder{composite: tc.composite, build: withKey(tc.key)}},
+				Writer:                       NewWriterWriter(io.Discard),
+				CompositeResourceDefinitions: []xapiextv1.CompositeResourceDefinition{xrd},
+			}).Build()
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("Build(): %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("Build() = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const (
+	errFmtDiffFile = "cannot compare %s"
+
+	diffContextLines = 3
+)
+
+// FileDiff is the difference between a file on disk and the content it is
+// generated with.
+type FileDiff struct {
+	// Path is the path of the file.
+	Path string
+
+	// Diff is a unified diff from the file on disk to the generated content.
+	// Files that would be created are diffed against /dev/null, as are files
+	// that would be deleted.
+	Diff string
+
+	// Deleted is true if the file is no longer generated.
+	Deleted bool
+}
+
+// DiffFile compares the file at the given path with the given generated
+// content. Nil content means the file is no longer generated and would be
+// deleted. Nil is returned if the file is up to date.
+func DiffFile(path string, content []byte) (*FileDiff, error) {
+	current, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, errFmtReadManifest, path)
+	}
+	exists := err == nil
+	if exists == (content != nil) && bytes.Equal(current, content) {
+		return nil, nil
+	}
+
+	from, to := path, path
+	if !exists {
+		from = os.DevNull
+	}
+	if content == nil {
+		to = os.DevNull
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(string(current)),
+		B:        splitLines(string(content)),
+		FromFile: from,
+		ToFile:   to,
+		Context:  diffContextLines,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, errFmtDiffFile, path)
+	}
+	if diff == "" {
+		// Creating or deleting an empty file changes no lines.
+		diff = fmt.Sprintf("--- %s\n+++ %s\n", from, to)
+	}
+	return &FileDiff{Path: path, Diff: diff, Deleted: content == nil}, nil
+}
+
+// splitLines splits the given text into lines for diffing. Unlike
+// difflib.SplitLines, empty text has no lines and a trailing newline does not
+// start another line.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if last := len(lines) - 1; lines[last] == "" {
+		lines = lines[:last]
+	} else {
+		lines[last] += "\n"
+	}
+	return lines
+}
+
+// CheckWriter is a CompositionWriter that writes nothing but compares the
+// compositions with the files a DirectoryWriter writes to the same
+// directory.
+type CheckWriter interface {
+	CompositionWriter
+
+	// Diff returns the differences between the files on disk and the
+	// compositions written so far, sorted by path. Files listed in the
+	// manifest of the directory that were not written are reported as
+	// deleted, as pruning would delete them. The manifest itself is compared
+	// with the one a DirectoryWriter records when pruning.
+	Diff() ([]FileDiff, error)
+}
+
+// NewCheckWriter creates a new CheckWriter comparing compositions with the
+// files a DirectoryWriter with the given config writes.
+func NewCheckWriter(config DirectoryWriterConfig) (CheckWriter, error) {
+	w, err := newDirectoryWriter(config)
+	if err != nil {
+		return nil, err
+	}
+	return &checkWriter{
+		directoryWriter: w,
+		files:           make(map[string][]byte),
+	}, nil
+}
+
+type checkWriter struct {
+	*directoryWriter
+	files map[string][]byte
+}
+
+func (w *checkWriter) Write(c xapiextv1.Composition) error {
+	path, b, err := w.render(c)
+	if err != nil {
+		return err
+	}
+	w.files[path] = b
+	return nil
+}
+
+func (w *checkWriter) Diff() ([]FileDiff, error) {
+	existing, err := w.generatedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	written := make([]string, 0, len(w.files))
+	for path := range w.files {
+		written = append(written, path)
+	}
+	manifest, err := w.manifestContent(written)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{filepath.Join(w.dir, ManifestFile): manifest}
+	for path, b := range w.files {
+		files[path] = b
+	}
+
+	paths := make([]string, 0, len(files)+len(existing))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	for _, path := range existing {
+		if _, ok := files[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var diffs []FileDiff
+	for _, path := range paths {
+		diff, err := DiffFile(path, files[path])
+		if err != nil {
+			return nil, err
+		}
+		if diff != nil {
+			diffs = append(diffs, *diff)
+		}
+	}
+	return diffs, nil
+}
@@ -0,0 +1,197 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestSplitLines(t *testing.T) {
+	cases := map[string]struct {
+		s    string
+		want []string
+	}{
+		"Empty":           {s: "", want: nil},
+		"TrailingNewline": {s: "a\nb\n", want: []string{"a\n", "b\n"}},
+		"NoTrailingNewline": {
+			s:    "a\nb",
+			want: []string{"a\n", "b\n"},
+		},
+		"EmptyLines": {s: "\n\n", want: []string{"\n", "\n"}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := splitLines(tc.s); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("splitLines(%q) = %q, want %q", tc.s, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDiffFile(t *testing.T) {
+	cases := map[string]struct {
+		current     *string
+		content     []byte
+		wantNil     bool
+		wantDeleted bool
+		wantLines   []string
+	}{
+		"UpToDate": {
+			current: ptr("a: 1\n"),
+			content: []byte("a: 1\n"),
+			wantNil: true,
+		},
+		"EmptyUpToDate": {
+			current: ptr(""),
+			content: []byte{},
+			wantNil: true,
+		},
+		"NotGeneratedNotOnDisk": {
+			wantNil: true,
+		},
+		"Changed": {
+			current:   ptr("a: 1\nb: 2\n"),
+			content:   []byte("a: 1\nb: 3\n"),
+			wantLines: []string{"--- FILE\n", "+++ FILE\n", " a: 1\n", "-b: 2\n", "+b: 3\n"},
+		},
+		"Created": {
+			content:   []byte("a: 1\n"),
+			wantLines: []string{"--- " + os.DevNull + "\n", "+++ FILE\n", "+a: 1\n"},
+		},
+		"CreatedEmpty": {
+			content:   []byte{},
+			wantLines: []string{"--- " + os.DevNull + "\n", "+++ FILE\n"},
+		},
+		"Deleted": {
+			current:     ptr("a: 1\n"),
+			wantDeleted: true,
+			wantLines:   []string{"--- FILE\n", "+++ " + os.DevNull + "\n", "-a: 1\n"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "composition.yaml")
+			if tc.current != nil {
+				if err := os.WriteFile(path, []byte(*tc.current), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := DiffFile(path, tc.content)
+			if err != nil {
+				t.Fatalf("DiffFile(): %v", err)
+			}
+			if tc.wantNil {
+				if got != nil {
+					t.Errorf("DiffFile() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("DiffFile() = nil, want a diff")
+			}
+			if got.Path != path || got.Deleted != tc.wantDeleted {
+				t.Errorf("DiffFile() path = %q, deleted = %t, want %q, %t", got.Path, got.Deleted, path, tc.wantDeleted)
+			}
+			for _, line := range tc.wantLines {
+				if line = strings.ReplaceAll(line, "FILE", path); !strings.Contains(got.Diff, line) {
+					t.Errorf("DiffFile() diff:\n%s\nwant line %q", got.Diff, line)
+				}
+			}
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestCheckWriterDiff(t *testing.T) {
+	composition := testComposition("a", "a.example.org/v1", "XA")
+	composition.SetGroupVersionKind(xapiextv1.CompositionGroupVersionKind)
+	content, err := yaml.Marshal(composition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := testComposition("b", "a.example.org/v1", "XA")
+	stale.SetGroupVersionKind(xapiextv1.CompositionGroupVersionKind)
+	staleContent, err := yaml.Marshal(stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := "{\n  \"files\": [\n    \"a.yaml\"\n  ]\n}\n"
+
+	cases := map[string]struct {
+		files       map[string]string
+		wantChanged []string
+	}{
+		"UpToDate": {
+			files: map[string]string{"a.yaml": string(content), ManifestFile: manifest},
+		},
+		"Changed": {
+			files:       map[string]string{"a.yaml": "kind: Composition\n", ManifestFile: manifest},
+			wantChanged: []string{"a.yaml"},
+		},
+		"Stale": {
+			files: map[string]string{
+				"a.yaml":     string(content),
+				"b.yaml":     string(staleContent),
+				ManifestFile: "{\n  \"files\": [\n    \"a.yaml\",\n    \"b.yaml\"\n  ]\n}\n",
+			},
+			wantChanged: []string{ManifestFile, "b.yaml"},
+		},
+		"StaleAlreadyDeleted": {
+			files: map[string]string{
+				"a.yaml":     string(content),
+				ManifestFile: "{\n  \"files\": [\n    \"a.yaml\",\n    \"b.yaml\"\n  ]\n}\n",
+			},
+			wantChanged: []string{ManifestFile},
+		},
+		"HandWrittenCompositionIgnored": {
+			files: map[string]string{"a.yaml": string(content), "b.yaml": string(staleContent), ManifestFile: manifest},
+		},
+		"HandWrittenIgnored": {
+			files: map[string]string{"a.yaml": string(content), "crossplane.yaml": "kind: Configuration\n", ManifestFile: manifest},
+		},
+		"ManifestMissing": {
+			files:       map[string]string{"a.yaml": string(content)},
+			wantChanged: []string{ManifestFile},
+		},
+		"ManifestOutdated": {
+			files:       map[string]string{"a.yaml": string(content), ManifestFile: "{\"files\": []}\n"},
+			wantChanged: []string{ManifestFile},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			for f, content := range tc.files {
+				writeTestFile(t, filepath.Join(dir, f), content)
+			}
+			w, err := NewCheckWriter(DirectoryWriterConfig{Dir: dir, Layout: "{{ .Name }}.yaml"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Write(composition); err != nil {
+				t.Fatal(err)
+			}
+
+			diffs, err := w.Diff()
+			if err != nil {
+				t.Fatalf("Diff(): %v", err)
+			}
+			var got []string
+			for _, d := range diffs {
+				got = append(got, strings.TrimPrefix(d.Path, dir+string(filepath.Separator)))
+			}
+			if !reflect.DeepEqual(got, tc.wantChanged) {
+				t.Errorf("Diff() = %q, want %q", got, tc.wantChanged)
+			}
+		})
+	}
+}
@@ -80,35 +80,60 @@ func (c *composeTemplateSkeleton) WithReadinessChecks(checks ...xapiextv1.Readin
 
 // ToComposedTemplate converts this composeTemplateSkeleton into a ComposedTemplate.
 func (c *composeTemplateSkeleton) ToComposedTemplate() (xapiextv1.ComposedTemplate, error) {
+	if err := c.base.resolve(); err != nil {
+		return xapiextv1.ComposedTemplate{}, errors.Wrap(err, errResolveBaseType)
+	}
+
+	c.RegisterAnnotations(KnownResourceAnnotations...)
+	c.RegisterLabels(KnownResourceLabels...)
+
+	base := c.validationBase()
+	if s, ok := base.(*openAPISchema); ok {
+		if err := s.validateObject(c.base.Object); err != nil {
+			return xapiextv1.ComposedTemplate{}, err
+		}
+	}
+
 	registeredCompositePaths, err := parseFieldPaths(c.compositionSkeleton.registeredPaths)
 	if err != nil {
 		return xapiextv1.ComposedTemplate{}, errors.Wrap(err, errParseRegisteredCompositePaths)
 	}
+	registeredEnvironmentPaths, err := parseFieldPaths(c.compositionSkeleton.registeredEnvironmentPaths)
+	if err != nil {
+		return xapiextv1.ComposedTemplate{}, errors.Wrap(err, errParseRegisteredEnvironmentPaths)
+	}
 	registeredPaths, err := parseFieldPaths(c.registeredPaths)
 	if err != nil {
 		return xapiextv1.ComposedTemplate{}, errors.Wrap(err, errParseRegisteredComposedPaths)
 	}
 
-	c.RegisterAnnotations(KnownResourceAnnotations...)
-	c.RegisterLabels(KnownResourceLabels...)
-
 	patches := make([]xapiextv1.Patch, len(c.patches))
 	for i, p := range c.patches {
-		if !p.unsafe {
-			if err := c.validatePatch(&c.patches[i].patch, registeredCompositePaths, registeredPaths); err != nil {
-				return xapiextv1.ComposedTemplate{}, errors.Wrapf(err, errFmtInvalidPatch, i)
-			}
+		var err error
+		switch {
+		case !p.unsafe:
+			err = c.validatePatch(&c.patches[i].patch, registeredCompositePaths, registeredEnvironmentPaths, registeredPaths)
+		case p.patch.Type == xapiextv1.PatchTypePatchSet:
+			// Patch sets must exist even if their patches are not validated.
+			_, err = c.compositionSkeleton.patchSetFor(&p.patch)
+		}
+		if err != nil {
+			return xapiextv1.ComposedTemplate{}, errors.Wrapf(err, errFmtInvalidPatch, i)
 		}
 		patches[i] = p.patch
 	}
 
-	base := c.base.Object
-	base.SetGroupVersionKind(c.base.GroupVersionKind)
+	if err := validateConnectionDetails(c.connectionDetails, base, registeredPaths); err != nil {
+		return xapiextv1.ComposedTemplate{}, err
+	}
+	if err := validateReadinessChecks(c.readinessChecks, base, registeredPaths); err != nil {
+		return xapiextv1.ComposedTemplate{}, err
+	}
 
 	return xapiextv1.ComposedTemplate{
 		Name: c.name,
 		Base: runtime.RawExtension{
-			Object: base,
+			Object: c.base.Object,
 		},
 		Patches:           patches,
 		ConnectionDetails: c.connectionDetails,
@@ -116,28 +141,55 @@ func (c *composeTemplateSkeleton) ToComposedTemplate() (xapiextv1.ComposedTempla
 	}, nil
 }
 
-func (c *composeTemplateSkeleton) validatePatch(patch *xapiextv1.Patch, registeredCompositePaths, registeredPaths []fieldpath.Segments) error {
+// validationBase returns the Go type or CRD schema paths into the base are
+// validated against.
+func (c *composeTemplateSkeleton) validationBase() interface{} {
+	return validationTarget(c.compositionSkeleton.crds, c.base.Object)
+}
+
+func (c *composeTemplateSkeleton) validatePatch(patch *xapiextv1.Patch, registeredCompositePaths, registeredEnvironmentPaths, registeredPaths []fieldpath.Segments) error {
+	environment := c.compositionSkeleton.environmentType
+	base := c.validationBase()
 	patchType := patch.Type
 	switch patchType {
 	case "", xapiextv1.PatchTypeFromCompositeFieldPath:
 		patch.Type = xapiextv1.PatchTypeFromCompositeFieldPath
-		return validatePatch(patch, c.compositionSkeleton.composite.Object, c.base.Object, registeredCompositePaths, registeredPaths)
+		return validatePatch(patch, c.compositionSkeleton.composite.Object, base, registeredCompositePaths, registeredPaths)
 	case xapiextv1.PatchTypeToCompositeFieldPath:
-		return validatePatch(patch, c.base.Object, c.compositionSkeleton.composite.Object, registeredPaths, registeredCompositePaths)
+		return validatePatch(patch, base, c.compositionSkeleton.composite.Object, registeredPaths, registeredCompositePaths)
 	case xapiextv1.PatchTypeCombineFromComposite:
-		return validatePatchCombine(patch, c.compositionSkeleton.composite.Object, c.base.Object, registeredCompositePaths, registeredPaths)
+		return validatePatchCombine(patch, c.compositionSkeleton.composite.Object, base, registeredCompositePaths, registeredPaths)
 	case xapiextv1.PatchTypeCombineToComposite:
-		return validatePatchCombine(patch, c.base.Object, c.compositionSkeleton.composite.Object, registeredPaths, registeredCompositePaths)
+		return validatePatchCombine(patch, base, c.compositionSkeleton.composite.Object, registeredPaths, registeredCompositePaths)
 	case xapiextv1.PatchTypePatchSet:
-		return errors.New("patch types not supported")
-		/*case xapiextv1.PatchTypeFromEnvironmentFieldPath:
-			return errors.New("patch types not supported")
-		case xapiextv1.PatchTypeToEnvironmentFieldPath:
-			return errors.New("patch types not supported")
-		case xapiextv1.PatchTypeCombineFromEnvironment:
-			return errors.New("patch types not supported")
-		case xapiextv1.PatchTypeCombineToEnvironment:
-			return errors.New("patch types not supported")*/
+		return c.validatePatchSet(patch, registeredCompositePaths, registeredEnvironmentPaths, registeredPaths)
+	case xapiextv1.PatchTypeFromEnvironmentFieldPath:
+		return validatePatch(patch, environment, base, registeredEnvironmentPaths, registeredPaths)
+	case xapiextv1.PatchTypeToEnvironmentFieldPath:
+		return validatePatch(patch, base, environment, registeredPaths, registeredEnvironmentPaths)
+	case xapiextv1.PatchTypeCombineFromEnvironment:
+		return validatePatchCombine(patch, environment, base, registeredEnvironmentPaths, registeredPaths)
+	case xapiextv1.PatchTypeCombineToEnvironment:
+		return validatePatchCombine(patch, base, environment, registeredPaths, registeredEnvironmentPaths)
 	}
 	return errors.Errorf(errUnknownPatchType, patchType)
 }
+
+// validatePatchSet validates the patches of the patch set referenced by the
+// given patch against this composeTemplateSkeleton.
+func (c *composeTemplateSkeleton) validatePatchSet(patch *xapiextv1.Patch, registeredCompositePaths, registeredEnvironmentPaths, registeredPaths []fieldpath.Segments) error {
+	ps, err := c.compositionSkeleton.patchSetFor(patch)
+	if err != nil {
+		return err
+	}
+	for i := range ps.Patches {
+		p := ps.Patches[i]
+		if p.Type == xapiextv1.PatchTypePatchSet {
+			return errors.Wrapf(errors.New(errNestedPatchSet), errFmtInvalidPatchSetPatch, i, ps.Name)
+		}
+		if err := c.validatePatch(&p, registeredCompositePaths, registeredEnvironmentPaths, registeredPaths); err != nil {
+			return errors.Wrapf(err, errFmtInvalidPatchSetPatch, i, ps.Name)
+		}
+	}
+	return nil
+}
@@ -5,9 +5,12 @@ import (
 	"log"
 
 	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/mproffitt/crossbuilder/pkg/generate/utils"
 	"github.com/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
 type compositionSkeleton struct {
@@ -18,9 +21,17 @@ type compositionSkeleton struct {
 	name                                    string
 	mode                                    xapiextv1.CompositionMode
 	composeTemplateSkeletons                []*composeTemplateSkeleton
+	patchSets                               []xapiextv1.PatchSet
+	environment                             *xapiextv1.EnvironmentConfiguration
+	environmentType                         interface{}
+	registeredEnvironmentPaths              []string
 	pipeline                                []*pipelineStepSkeleton
 	publishConnectionDetailsWithStoreConfig *xapiextv1.StoreConfigReference
 	writeConnectionSecretsToNamespace       *string
+	xrd                                     *xapiextv1.CompositeResourceDefinition
+	convertToPipeline                       bool
+	crds                                    []extv1.CustomResourceDefinition
+	errs                                    []error
 }
 
 // RegisterCompositeAnnotations marks the given composite annotations as safe so
@@ -86,6 +97,57 @@ func (c *compositionSkeleton) WithMode(mode xapiextv1.CompositionMode) Compositi
 	return c
 }
 
+// WithEnvironment sets the environment of the composition to be built.
+func (c *compositionSkeleton) WithEnvironment(env xapiextv1.EnvironmentConfiguration) CompositionSkeleton {
+	c.environment = &env
+	return c
+}
+
+// WithEnvironmentType sets the type describing the data of the environment.
+func (c *compositionSkeleton) WithEnvironmentType(obj interface{}) CompositionSkeleton {
+	c.environmentType = obj
+	return c
+}
+
+// RegisterEnvironmentFieldPaths marks the given environment paths as safe so
+// they will be treated as valid in patch paths.
+func (c *compositionSkeleton) RegisterEnvironmentFieldPaths(paths ...string) CompositionSkeleton {
+	c.registeredEnvironmentPaths = append(c.registeredEnvironmentPaths, paths...)
+	return c
+}
+
+// WithPatchSet adds the following patches to the patch set with the given
+// name.
+func (c *compositionSkeleton) WithPatchSet(name string, patches ...xapiextv1.Patch) CompositionSkeleton {
+	if ps := c.patchSet(name); ps != nil {
+		ps.Patches = append(ps.Patches, patches...)
+		return c
+	}
+	c.patchSets = append(c.patchSets, xapiextv1.PatchSet{
+		Name:    name,
+		Patches: patches,
+	})
+	return c
+}
+
+// patchSetFor returns the patch set the given patch of type PatchSet refers to.
+func (c *compositionSkeleton) patchSetFor(patch *xapiextv1.Patch) (*xapiextv1.PatchSet, error) {
+	name := utils.StringValue(patch.PatchSetName)
+	if ps := c.patchSet(name); ps != nil {
+		return ps, nil
+	}
+	return nil, errors.Errorf(errFmtUnknownPatchSet, name)
+}
+
+func (c *compositionSkeleton) patchSet(name string) *xapiextv1.PatchSet {
+	for i := range c.patchSets {
+		if c.patchSets[i].Name == name {
+			return &c.patchSets[i]
+		}
+	}
+	return nil
+}
+
 // NewResource creates a new composeTemplateSkeleton with the given base.
 func (c *compositionSkeleton) NewResource(base ObjectKindReference) ComposedTemplateSkeleton {
 	res := &composeTemplateSkeleton{
@@ -96,16 +158,110 @@ func (c *compositionSkeleton) NewResource(base ObjectKindReference) ComposedTemp
 	return res
 }
 
+// NewPipelineStep appends a new step with the given name to the pipeline.
 func (c *compositionSkeleton) NewPipelineStep(step string) PipelineStepSkeleton {
-	ps := &pipelineStepSkeleton{
+	ps := c.newPipelineStep(step)
+	c.pipeline = append(c.pipeline, ps)
+	return ps
+}
+
+// InsertStepBefore inserts a new step with the given name directly before the
+// existing step named before.
+func (c *compositionSkeleton) InsertStepBefore(before, step string) PipelineStepSkeleton {
+	return c.insertStep(before, step, 0)
+}
+
+// InsertStepAfter inserts a new step with the given name directly after the
+// existing step named after.
+func (c *compositionSkeleton) InsertStepAfter(after, step string) PipelineStepSkeleton {
+	return c.insertStep(after, step, 1)
+}
+
+// ReplaceStep replaces the existing step with the given name by a new, empty
+// step at the same position.
+func (c *compositionSkeleton) ReplaceStep(step string) PipelineStepSkeleton {
+	ps := c.newPipelineStep(step)
+	i := c.stepIndex(step)
+	if i == -1 {
+		c.errs = append(c.errs, errors.Errorf(errFmtStepNotFound, step))
+		return ps
+	}
+	c.pipeline[i] = ps
+	return ps
+}
+
+// RemoveStep removes the step with the given name from the pipeline.
+func (c *compositionSkeleton) RemoveStep(step string) CompositionSkeleton {
+	i := c.stepIndex(step)
+	if i == -1 {
+		c.errs = append(c.errs, errors.Errorf(errFmtStepNotFound, step))
+		return c
+	}
+	c.pipeline = append(c.pipeline[:i], c.pipeline[i+1:]...)
+	return c
+}
+
+// GetStep returns the step with the given name. If there is no such step
+// the error is recorded and a step that is not part of the pipeline is
+// returned.
+func (c *compositionSkeleton) GetStep(step string) PipelineStepSkeleton {
+	i := c.stepIndex(step)
+	if i == -1 {
+		c.errs = append(c.errs, errors.Errorf(errFmtStepNotFound, step))
+		return c.newPipelineStep(step)
+	}
+	return c.pipeline[i]
+}
+
+func (c *compositionSkeleton) newPipelineStep(step string) *pipelineStepSkeleton {
+	return &pipelineStepSkeleton{
 		step:                step,
 		compositionSkeleton: c,
 	}
+}
+
+// insertStep inserts a new step at the given offset relative to the step
+// named anchor. If the anchor does not exist the error is recorded and the
+// step is returned without being added to the pipeline.
+func (c *compositionSkeleton) insertStep(anchor, step string, offset int) PipelineStepSkeleton {
+	ps := c.newPipelineStep(step)
+	i := c.stepIndex(anchor)
+	if i == -1 {
+		c.errs = append(c.errs, errors.Errorf(errFmtStepNotFound, anchor))
+		return ps
+	}
 
-	c.pipeline = append(c.pipeline, ps)
+	i += offset
+	c.pipeline = append(c.pipeline[:i], append([]*pipelineStepSkeleton{ps}, c.pipeline[i:]...)...)
 	return ps
 }
 
+func (c *compositionSkeleton) stepIndex(step string) int {
+	for i, p := range c.pipeline {
+		if p.step == step {
+			return i
+		}
+	}
+	return -1
+}
+
+// orderedPipeline returns the pipeline with all steps pinned to the start or
+// end moved into place.
+func (c *compositionSkeleton) orderedPipeline() []*pipelineStepSkeleton {
+	var first, middle, last []*pipelineStepSkeleton
+	for _, p := range c.pipeline {
+		switch p.position {
+		case stepPositionFirst:
+			first = append(first, p)
+		case stepPositionLast:
+			last = append(last, p)
+		default:
+			middle = append(middle, p)
+		}
+	}
+	return append(append(first, middle...), last...)
+}
+
 // WithPublishConnectionDetailsWithStoreConfig sets the
 // PublishConnectionDetailsWithStoreConfig of this CompositionSkeleton.
 func (c *compositionSkeleton) WithPublishConnectionDetailsWithStoreConfig(ref *xapiextv1.StoreConfigReference) CompositionSkeleton {
@@ -125,32 +281,65 @@ func (c *compositionSkeleton) ToComposition() (xapiextv1.Composition, error) {
 	if c.name == "" {
 		return xapiextv1.Composition{}, errors.New(errEmptyCompositionname)
 	}
+	if len(c.errs) > 0 {
+		return xapiextv1.Composition{}, kerrors.NewAggregate(c.errs)
+	}
+
+	if err := c.composite.resolve(); err != nil {
+		return xapiextv1.Composition{}, errors.Wrap(err, errResolveCompositeType)
+	}
 
 	c.RegisterCompositeAnnotations(KnownCompositeAnnotations...)
 	c.RegisterCompositeLabels(KnownCompositeLabels...)
 
+	environment, err := c.setupEnvironment()
+	if err != nil {
+		return xapiextv1.Composition{}, errors.Wrap(err, errFmtSetupComposition)
+	}
+
 	var (
 		composedTemplates []xapiextv1.ComposedTemplate
+		patchSets         []xapiextv1.PatchSet
 		pipelineSteps     []xapiextv1.PipelineStep
-		err               error
+		mode              xapiextv1.CompositionMode
 	)
 
 	switch c.mode {
 	case "", xapiextv1.CompositionModeResources:
 		c.mode = xapiextv1.CompositionModeResources
 		composedTemplates, err = c.setupComposed()
+		patchSets = c.patchSets
+		if err == nil {
+			err = errors.Wrap(validateConnectionSecretKeys(c.xrd, connectionSecretKeys(composedTemplates), true), errInvalidConnectionSecretKeys)
+		}
+		if err == nil && c.convertToPipeline {
+			mode = xapiextv1.CompositionModePipeline
+			pipelineSteps, environment, err = convertToPipeline(composedTemplates, patchSets, environment)
+			err = errors.Wrap(err, errConvertToPipeline)
+			composedTemplates, patchSets = nil, nil
+		}
 	case xapiextv1.CompositionModePipeline:
 		pipelineSteps, err = c.setupPipeline()
+		if err == nil {
+			// Other functions may add connection details as well, so only
+			// the keys produced by patch-and-transform are checked.
+			err = errors.Wrap(validateConnectionSecretKeys(c.xrd, composedConnectionSecretKeys(pipelineSteps), false), errInvalidConnectionSecretKeys)
+		}
 	}
 
 	if err != nil {
 		return xapiextv1.Composition{}, errors.Wrap(err, errFmtSetupComposition)
 	}
+	if mode == "" {
+		mode = c.mode
+	}
 
 	comp := xapiextv1.Composition{
 		Spec: xapiextv1.CompositionSpec{
 			CompositeTypeRef:                  xapiextv1.TypeReferenceTo(c.composite.GroupVersionKind),
-			Mode:                              &c.mode,
+			Mode:                              &mode,
+			PatchSets:                         patchSets,
+			Environment:                       environment,
 			Resources:                         composedTemplates,
 			Pipeline:                          pipelineSteps,
 			WriteConnectionSecretsToNamespace: c.writeConnectionSecretsToNamespace,
@@ -166,6 +355,31 @@ func (c *compositionSkeleton) ToComposition() (xapiextv1.Composition, error) {
 	return comp, nil
 }
 
+func (c *compositionSkeleton) setupEnvironment() (*xapiextv1.EnvironmentConfiguration, error) {
+	if c.environment == nil {
+		return nil, nil
+	}
+	if errs := c.environment.Validate(); len(errs) > 0 {
+		return nil, errors.Wrap(errs.ToAggregate(), errInvalidEnvironment)
+	}
+
+	registeredCompositePaths, err := parseFieldPaths(c.registeredPaths)
+	if err != nil {
+		return nil, errors.Wrap(err, errParseRegisteredCompositePaths)
+	}
+	registeredEnvironmentPaths, err := parseFieldPaths(c.registeredEnvironmentPaths)
+	if err != nil {
+		return nil, errors.Wrap(err, errParseRegisteredEnvironmentPaths)
+	}
+
+	for i := range c.environment.Patches {
+		if err := validateEnvironmentPatch(&c.environment.Patches[i], c.composite.Object, c.environmentType, registeredCompositePaths, registeredEnvironmentPaths); err != nil {
+			return nil, errors.Wrapf(err, errFmtInvalidEnvironmentPatch, i)
+		}
+	}
+	return c.environment, nil
+}
+
 func (c *compositionSkeleton) setupComposed() ([]xapiextv1.ComposedTemplate, error) {
 	if c.mode != xapiextv1.CompositionModeResources {
 		return nil, errors.New(errInvalidResourcesMode)
@@ -186,25 +400,80 @@ func (c *compositionSkeleton) setupPipeline() ([]xapiextv1.PipelineStep, error)
 	if c.mode != xapiextv1.CompositionModePipeline {
 		return nil, errors.New(errInvalidPipelineMode)
 	}
+	if len(c.composeTemplateSkeletons) > 0 {
+		return nil, errors.New(errResourcesInPipelineMode)
+	}
+	if len(c.patchSets) > 0 {
+		return nil, errors.New(errPatchSetsInPipelineMode)
+	}
+	if len(c.pipeline) == 0 {
+		return nil, errors.New(errEmptyPipeline)
+	}
 
-	pipelineSteps := make([]xapiextv1.PipelineStep, len(c.pipeline))
-	for i, p := range c.pipeline {
-		pipelineSteps[i] = toPipelineStep(p)
+	registeredCompositePaths, err := parseFieldPaths(c.registeredPaths)
+	if err != nil {
+		return nil, errors.Wrap(err, errParseRegisteredCompositePaths)
+	}
+
+	pipeline := c.orderedPipeline()
+	pipelineSteps := make([]xapiextv1.PipelineStep, len(pipeline))
+	seen := make(map[string]int, len(pipeline))
+	for i, p := range pipeline {
+		ps, err := toPipelineStep(p)
+		if err == nil {
+			err = validatePipelineStep(ps)
+		}
+		if err == nil {
+			err = validateStepTemplate(ps, c.composite.Object, registeredCompositePaths)
+		}
+		if err == nil {
+			if j, ok := seen[ps.Step]; ok {
+				err = errors.Errorf(errFmtDuplicateStepName, ps.Step, j)
+			}
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtInvalidPipelineStep, p.step, i)
+		}
+		seen[ps.Step] = i
+		pipelineSteps[i] = ps
 		log.Printf("(%s) step: %q\n", c.name, p.step)
 	}
 	return pipelineSteps, nil
 }
 
-func toPipelineStep(p *pipelineStepSkeleton) xapiextv1.PipelineStep {
-	var object xapiextv1.PipelineStep = xapiextv1.PipelineStep{
+func toPipelineStep(p *pipelineStepSkeleton) (xapiextv1.PipelineStep, error) {
+	functionRef, input, err := p.resolveFunction()
+	if err != nil {
+		return xapiextv1.PipelineStep{}, err
+	}
+
+	// Patches can only be applied by patch-and-transform so register it as
+	// the function for this step if nothing else has been set.
+	if len(p.patches) > 0 && functionRef == nil {
+		functionRef = &xapiextv1.FunctionReference{
+			Name: FunctionPatchAndTransform,
+		}
+	}
+
+	if functionRef == nil {
+		return xapiextv1.PipelineStep{}, errors.New(errMissingFunctionRef)
+	}
+
+	object, err := p.prepareInput(input)
+	if err != nil {
+		return xapiextv1.PipelineStep{}, err
+	}
+
+	var step xapiextv1.PipelineStep = xapiextv1.PipelineStep{
 		Step:        p.step,
-		FunctionRef: *p.functionRef,
+		FunctionRef: *functionRef,
+		Credentials: p.credentials,
 	}
 
-	if p.input != nil {
-		object.Input = &runtime.RawExtension{
-			Object: p.input.Object,
+	if object != nil {
+		step.Input = &runtime.RawExtension{
+			Object: object,
 		}
 	}
-	return object
+	return step, nil
 }
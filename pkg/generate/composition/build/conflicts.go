@@ -0,0 +1,105 @@
+package build
+
+import (
+	"fmt"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+)
+
+const (
+	errFmtDuplicateCompositionName = "composition name %q is also used by %s"
+	errFmtDuplicateOutputPath      = "output path %q is also used by %s"
+	errFmtDuplicateXRDName         = "composite resource definition %q is defined at index %d and %d"
+
+	fmtCompositionAtIndex = "the composition at index %d"
+	fmtFromSource         = " from %s"
+)
+
+// outputPathResolver is implemented by CompositionWriters that write each
+// composition to its own file.
+type outputPathResolver interface {
+	// outputPath returns the path the given composition is written to.
+	outputPath(c xapiextv1.Composition) (string, error)
+}
+
+// removeConflicts returns an error for each composition whose name, or the
+// path it is written to, is already used by another composition. Whichever
+// composition would be written last would silently win, so all compositions
+// involved are removed from the given ones.
+func (b *compositionBuildRunner) removeConflicts(compositions []builtComposition) ([]builtComposition, []*BuildError) {
+	var errs []*BuildError
+	conflicting := make(map[int]bool)
+	names := make(map[string]builtComposition)
+	paths := make(map[string]builtComposition)
+	resolver, _ := b.config.Writer.(outputPathResolver)
+
+	for _, c := range compositions {
+		name := c.composition.GetName()
+		if other, ok := names[name]; ok {
+			errs = append(errs, b.conflict(c, other, conflicting, errFmtDuplicateCompositionName, name)...)
+			continue
+		}
+		names[name] = c
+
+		if resolver == nil {
+			continue
+		}
+		path, err := resolver.outputPath(c.composition)
+		if err != nil {
+			continue // reported when the composition is written
+		}
+		if other, ok := paths[path]; ok {
+			errs = append(errs, b.conflict(c, other, conflicting, errFmtDuplicateOutputPath, path)...)
+			continue
+		}
+		paths[path] = c
+	}
+
+	if len(conflicting) == 0 {
+		return compositions, nil
+	}
+	remaining := make([]builtComposition, 0, len(compositions)-len(conflicting))
+	for _, c := range compositions {
+		if !conflicting[c.index] {
+			remaining = append(remaining, c)
+		}
+	}
+	return remaining, errs
+}
+
+// conflict marks both given compositions as conflicting and returns an
+// error for each that is not marked yet, naming the other one.
+func (b *compositionBuildRunner) conflict(c, other builtComposition, conflicting map[int]bool, format, value string) []*BuildError {
+	errs := []*BuildError{
+		b.compositionError(c, errors.Errorf(format, value, b.describe(other.index))),
+	}
+	if !conflicting[other.index] {
+		errs = append(errs, b.compositionError(other, errors.Errorf(format, value, b.describe(c.index))))
+	}
+	conflicting[c.index], conflicting[other.index] = true, true
+	return errs
+}
+
+// describe returns a description of the composition built by the builder at
+// the given index for use in errors.
+func (b *compositionBuildRunner) describe(index int) string {
+	d := fmt.Sprintf(fmtCompositionAtIndex, index)
+	if source := b.source(index); source != "" {
+		d += fmt.Sprintf(fmtFromSource, source)
+	}
+	return d
+}
+
+// validateXRDNames returns an error if two of the given XRDs have the same
+// name.
+func validateXRDNames(xrds []xapiextv1.CompositeResourceDefinition) error {
+	names := make(map[string]int, len(xrds))
+	for i, xrd := range xrds {
+		if other, ok := names[xrd.GetName()]; ok {
+			return errors.Errorf(errFmtDuplicateXRDName, xrd.GetName(), other, i)
+		}
+		names[xrd.GetName()] = i
+	}
+	return nil
+}
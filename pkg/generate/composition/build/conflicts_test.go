@@ -0,0 +1,158 @@
+package build
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRemoveConflicts(t *testing.T) {
+	built := func(compositions ...xapiextv1.Composition) []builtComposition {
+		b := make([]builtComposition, len(compositions))
+		for i, c := range compositions {
+			b[i] = builtComposition{index: i, composition: c}
+		}
+		return b
+	}
+	dirWriter, err := NewDirectoryWriterFromConfig(DirectoryWriterConfig{Dir: "apis", Layout: "{{ .Kind }}.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		writer        CompositionWriter
+		compositions  []builtComposition
+		wantRemaining []int
+		wantErrs      []string
+	}{
+		"NoConflicts": {
+			writer: dirWriter,
+			compositions: built(
+				testComposition("a", "a.example.org/v1", "XA"),
+				testComposition("b", "a.example.org/v1", "XB"),
+			),
+			wantRemaining: []int{0, 1},
+		},
+		"DuplicateName": {
+			writer: NewWriterWriter(io.Discard),
+			compositions: built(
+				testComposition("a", "a.example.org/v1", "XA"),
+				testComposition("b", "a.example.org/v1", "XA"),
+				testComposition("a", "b.example.org/v1", "XB"),
+			),
+			wantRemaining: []int{1},
+			wantErrs: []string{
+				`2: composition name "a" is also used by the composition at index 0 from a.so`,
+				`0: composition name "a" is also used by the composition at index 2 from c.so`,
+			},
+		},
+		"ThreeWayDuplicateName": {
+			writer: NewWriterWriter(io.Discard),
+			compositions: built(
+				testComposition("a", "a.example.org/v1", "XA"),
+				testComposition("a", "a.example.org/v1", "XA"),
+				testComposition("a", "a.example.org/v1", "XA"),
+			),
+			wantErrs: []string{
+				`1: composition name "a" is also used by the composition at index 0 from a.so`,
+				`0: composition name "a" is also used by the composition at index 1 from b.so`,
+				`2: composition name "a" is also used by the composition at index 0 from a.so`,
+			},
+		},
+		"DuplicateOutputPath": {
+			writer: dirWriter,
+			compositions: built(
+				testComposition("a", "a.example.org/v1", "XA"),
+				testComposition("b", "b.example.org/v1", "XA"),
+				testComposition("c", "a.example.org/v1", "XC"),
+			),
+			wantRemaining: []int{2},
+			wantErrs: []string{
+				`1: output path "apis/XA.yaml" is also used by the composition at index 0 from a.so`,
+				`0: output path "apis/XA.yaml" is also used by the composition at index 1 from b.so`,
+			},
+		},
+		"OutputPathIgnoredByStreams": {
+			writer: NewStreamWriter(io.Discard),
+			compositions: built(
+				testComposition("a", "a.example.org/v1", "XA"),
+				testComposition("b", "b.example.org/v1", "XA"),
+			),
+			wantRemaining: []int{0, 1},
+		},
+		"InvalidOutputPathLeftToWriter": {
+			writer: dirWriter,
+			compositions: built(
+				testComposition("a", "a.example.org/v1/invalid", "XA"),
+				testComposition("b", "a.example.org/v1/invalid", "XA"),
+			),
+			wantRemaining: []int{0, 1},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &compositionBuildRunner{config: RunnerConfig{
+				Writer:  tc.writer,
+				Sources: []string{"a.so", "b.so", "c.so"},
+			}}
+			remaining, errs := r.removeConflicts(tc.compositions)
+
+			var gotRemaining []int
+			for _, c := range remaining {
+				gotRemaining = append(gotRemaining, c.index)
+			}
+			if !reflect.DeepEqual(gotRemaining, tc.wantRemaining) {
+				t.Errorf("removeConflicts() kept %v, want %v", gotRemaining, tc.wantRemaining)
+			}
+
+			var gotErrs []string
+			for _, e := range errs {
+				gotErrs = append(gotErrs, strings.TrimPrefix(e.Error(), "composition at index "))
+			}
+			if len(gotErrs) != len(tc.wantErrs) {
+				t.Fatalf("removeConflicts() errors = %q, want %q", gotErrs, tc.wantErrs)
+			}
+			for i, want := range tc.wantErrs {
+				index, msg, _ := strings.Cut(want, ": ")
+				if !strings.HasPrefix(gotErrs[i], index+" ") || !strings.HasSuffix(gotErrs[i], msg) {
+					t.Errorf("removeConflicts() error %d = %q, want %q", i, gotErrs[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateXRDNames(t *testing.T) {
+	xrd := func(name string) xapiextv1.CompositeResourceDefinition {
+		return xapiextv1.CompositeResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+
+	cases := map[string]struct {
+		xrds    []xapiextv1.CompositeResourceDefinition
+		wantErr string
+	}{
+		"None": {},
+		"Unique": {
+			xrds: []xapiextv1.CompositeResourceDefinition{xrd("a"), xrd("b")},
+		},
+		"Duplicate": {
+			xrds:    []xapiextv1.CompositeResourceDefinition{xrd("a"), xrd("b"), xrd("a")},
+			wantErr: `composite resource definition "a" is defined at index 0 and 2`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateXRDNames(tc.xrds)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("validateXRDNames(): %v", err)
+			case tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr):
+				t.Errorf("validateXRDNames() = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
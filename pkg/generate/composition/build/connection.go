@@ -0,0 +1,142 @@
+package build
+
+import (
+	"sort"
+
+	xpt "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/mproffitt/crossbuilder/pkg/generate/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	errFmtInvalidConnectionDetail         = "invalid connection detail at index %d"
+	errFmtInvalidResourceConnectionDetail = "invalid connection details for resource %q"
+	errConnectionDetailFromFieldPath      = "fromFieldPath is invalid"
+	errFmtUnadvertisedConnectionSecretKey = "connection secret key %q is not advertised by the XRD %s"
+	errFmtMissingConnectionSecretKey      = "connection secret key %q advertised by the XRD %s is not produced by any composed resource"
+	errInvalidConnectionSecretKeys        = "invalid connection secret keys"
+)
+
+// isFromFieldPath returns true if the given connection detail reads its value
+// from a field of the composed resource. Crossplane infers the type from the
+// fields set if it is omitted, preferring values and secret keys.
+func isFromFieldPath(cd xapiextv1.ConnectionDetail) bool {
+	if cd.Type != nil {
+		return *cd.Type == xapiextv1.ConnectionDetailTypeFromFieldPath
+	}
+	return cd.Value == nil && cd.FromConnectionSecretKey == nil && cd.FromFieldPath != nil
+}
+
+// validateConnectionDetails validates the field paths of all connection
+// details reading from the given base.
+func validateConnectionDetails(details []xapiextv1.ConnectionDetail, base interface{}, knownPaths []fieldpath.Segments) error {
+	if !canValidate(base) {
+		return nil
+	}
+	for i, cd := range details {
+		if !isFromFieldPath(cd) {
+			continue
+		}
+		if err := ValidateFieldPath(base, utils.StringValue(cd.FromFieldPath), knownPaths); err != nil {
+			return errors.Wrapf(errors.Wrap(err, errConnectionDetailFromFieldPath), errFmtInvalidConnectionDetail, i)
+		}
+	}
+	return nil
+}
+
+// validateComposedConnectionDetails validates the field paths of all
+// patch-and-transform connection details reading from the given base.
+func validateComposedConnectionDetails(details []xpt.ConnectionDetail, base interface{}, knownPaths []fieldpath.Segments) error {
+	if !canValidate(base) {
+		return nil
+	}
+	for i, cd := range details {
+		if cd.Type != xpt.ConnectionDetailTypeFromFieldPath {
+			continue
+		}
+		if err := ValidateFieldPath(base, utils.StringValue(cd.FromFieldPath), knownPaths); err != nil {
+			return errors.Wrapf(errors.Wrap(err, errConnectionDetailFromFieldPath), errFmtInvalidConnectionDetail, i)
+		}
+	}
+	return nil
+}
+
+// connectionSecretKeys returns the sorted keys the given composed templates
+// write to the connection secret of the composite. A connection detail
+// without a name uses the key it is read from.
+func connectionSecretKeys(templates []xapiextv1.ComposedTemplate) []string {
+	keys := make(map[string]bool)
+	for _, t := range templates {
+		for _, cd := range t.ConnectionDetails {
+			name := utils.StringValue(cd.Name)
+			if name == "" {
+				name = utils.StringValue(cd.FromConnectionSecretKey)
+			}
+			if name != "" {
+				keys[name] = true
+			}
+		}
+	}
+	return sortedKeys(keys)
+}
+
+// composedConnectionSecretKeys returns the sorted keys the
+// patch-and-transform steps of the given pipeline write to the connection
+// secret of the composite.
+func composedConnectionSecretKeys(steps []xapiextv1.PipelineStep) []string {
+	keys := make(map[string]bool)
+	for _, s := range steps {
+		if s.Input == nil {
+			continue
+		}
+		resources, ok := s.Input.Object.(*xpt.Resources)
+		if !ok {
+			continue
+		}
+		for _, r := range resources.Resources {
+			for _, cd := range r.ConnectionDetails {
+				keys[cd.Name] = true
+			}
+		}
+	}
+	return sortedKeys(keys)
+}
+
+func sortedKeys(keys map[string]bool) []string {
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// validateConnectionSecretKeys checks the produced connection secret keys
+// against the keys advertised by the given XRD. XRDs advertising no keys
+// accept any key. If all is true every advertised key must be produced.
+func validateConnectionSecretKeys(xrd *xapiextv1.CompositeResourceDefinition, produced []string, all bool) error {
+	if xrd == nil || len(xrd.Spec.ConnectionSecretKeys) == 0 {
+		return nil
+	}
+	advertised := make(map[string]bool, len(xrd.Spec.ConnectionSecretKeys))
+	for _, k := range xrd.Spec.ConnectionSecretKeys {
+		advertised[k] = true
+	}
+	for _, k := range produced {
+		if !advertised[k] {
+			return errors.Errorf(errFmtUnadvertisedConnectionSecretKey, k, xrd.GetName())
+		}
+	}
+	if !all {
+		return nil
+	}
+	for _, k := range produced {
+		delete(advertised, k)
+	}
+	if missing := sortedKeys(advertised); len(missing) > 0 {
+		return errors.Errorf(errFmtMissingConnectionSecretKey, missing[0], xrd.GetName())
+	}
+	return nil
+}
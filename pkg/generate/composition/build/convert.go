@@ -0,0 +1,233 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+
+	xpt "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/mproffitt/crossbuilder/pkg/generate/utils"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	errConvertToPipeline          = "cannot convert composition to pipeline mode"
+	errFmtConvertComposedTemplate = "cannot convert composed template at index %d"
+	errFmtConvertPatchSet         = "cannot convert patch set %q"
+	errFmtConvertEnvironmentPatch = "cannot convert environment patch at index %d"
+	errFmtConvertConnectionDetail = "cannot convert connection detail at index %d"
+	errFmtUnknownConnectionDetail = "cannot infer the type of connection detail %q"
+
+	fmtGeneratedResourceName = "resource-%d"
+)
+
+// convertToPipeline converts the composed templates, patch sets and
+// environment patches of a Resources mode composition into the equivalent
+// pipeline. Resources are composed by a function-patch-and-transform step,
+// followed by function-auto-ready. The environment is returned without
+// patches as they are now applied by function-patch-and-transform.
+func convertToPipeline(templates []xapiextv1.ComposedTemplate, patchSets []xapiextv1.PatchSet, env *xapiextv1.EnvironmentConfiguration) ([]xapiextv1.PipelineStep, *xapiextv1.EnvironmentConfiguration, error) {
+	resources := &xpt.Resources{}
+	resources.SetGroupVersionKind(ResourcesGroupVersionKind)
+
+	for _, ps := range patchSets {
+		converted := xpt.PatchSet{
+			Name:    ps.Name,
+			Patches: make([]xpt.PatchSetPatch, len(ps.Patches)),
+		}
+		for i := range ps.Patches {
+			if err := convertPatch(&ps.Patches[i], &converted.Patches[i]); err != nil {
+				return nil, nil, errors.Wrapf(errors.Wrapf(err, errFmtInvalidPatch, i), errFmtConvertPatchSet, ps.Name)
+			}
+			converted.Patches[i].Policy = toPatchPolicy(ps.Patches[i].Policy)
+		}
+		resources.PatchSets = append(resources.PatchSets, converted)
+	}
+
+	for i, t := range templates {
+		ct, err := convertComposedTemplate(t, i)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, errFmtConvertComposedTemplate, i)
+		}
+		resources.Resources = append(resources.Resources, ct)
+	}
+
+	if env != nil {
+		if len(env.Patches) > 0 {
+			resources.Environment = &xpt.Environment{
+				Patches: make([]xpt.EnvironmentPatch, len(env.Patches)),
+			}
+		}
+		for i := range env.Patches {
+			if err := convertPatch(&env.Patches[i], &resources.Environment.Patches[i]); err != nil {
+				return nil, nil, errors.Wrapf(err, errFmtConvertEnvironmentPatch, i)
+			}
+			resources.Environment.Patches[i].Policy = toPatchPolicy(env.Patches[i].Policy)
+		}
+
+		env = env.DeepCopy()
+		env.Patches = nil
+		if len(env.EnvironmentConfigs) == 0 && len(env.DefaultData) == 0 && env.Policy == nil {
+			env = nil
+		}
+	}
+
+	steps := []xapiextv1.PipelineStep{
+		{
+			Step:        FunctionPatchAndTransform,
+			FunctionRef: xapiextv1.FunctionReference{Name: FunctionPatchAndTransform},
+			Input:       &runtime.RawExtension{Object: resources},
+		},
+		{
+			Step:        FunctionAutoReady,
+			FunctionRef: xapiextv1.FunctionReference{Name: FunctionAutoReady},
+		},
+	}
+	for i, step := range steps {
+		if err := validatePipelineStep(step); err != nil {
+			return nil, nil, errors.Wrapf(err, errFmtInvalidPipelineStep, step.Step, i)
+		}
+	}
+	return steps, env, nil
+}
+
+// convertComposedTemplate converts the given composed template into its
+// function-patch-and-transform equivalent. Templates without a name are named
+// after their index as function-patch-and-transform requires names.
+func convertComposedTemplate(t xapiextv1.ComposedTemplate, index int) (xpt.ComposedTemplate, error) {
+	name := utils.StringValue(t.Name)
+	if name == "" {
+		name = fmt.Sprintf(fmtGeneratedResourceName, index)
+	}
+	ct := xpt.ComposedTemplate{
+		Name: name,
+		Base: t.Base.DeepCopy(),
+	}
+
+	for i := range t.Patches {
+		var p xpt.ComposedPatch
+		if err := convertPatch(&t.Patches[i], &p); err != nil {
+			return xpt.ComposedTemplate{}, errors.Wrapf(err, errFmtInvalidPatch, i)
+		}
+		p.Policy = toPatchPolicy(t.Patches[i].Policy)
+		ct.Patches = append(ct.Patches, p)
+	}
+
+	for i, cd := range t.ConnectionDetails {
+		converted, err := convertConnectionDetail(cd)
+		if err != nil {
+			return xpt.ComposedTemplate{}, errors.Wrapf(err, errFmtConvertConnectionDetail, i)
+		}
+		ct.ConnectionDetails = append(ct.ConnectionDetails, converted)
+	}
+
+	for _, rc := range t.ReadinessChecks {
+		ct.ReadinessChecks = append(ct.ReadinessChecks, convertReadinessCheck(rc))
+	}
+	return ct, nil
+}
+
+// convertPatch converts the given Crossplane patch into the given
+// function-patch-and-transform patch. The patch policy differs between both
+// and is left for the caller to convert with toPatchPolicy.
+func convertPatch(in, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return errors.Wrap(err, errConvertPatch)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return errors.Wrap(err, errConvertPatch)
+	}
+	delete(fields, "policy")
+
+	if b, err = json.Marshal(fields); err != nil {
+		return errors.Wrap(err, errConvertPatch)
+	}
+	return errors.Wrap(json.Unmarshal(b, out), errConvertPatch)
+}
+
+// toPatchPolicy converts the given patch policy. Merge options are replaced
+// by the toFieldPath policy function-patch-and-transform uses instead.
+func toPatchPolicy(policy *xapiextv1.PatchPolicy) *xpt.PatchPolicy {
+	if policy == nil {
+		return nil
+	}
+	out := &xpt.PatchPolicy{}
+	if policy.FromFieldPath != nil {
+		from := xpt.FromFieldPathPolicy(*policy.FromFieldPath)
+		out.FromFieldPath = &from
+	}
+	if mo := policy.MergeOptions; mo != nil {
+		keepMapValues := mo.KeepMapValues != nil && *mo.KeepMapValues
+		appendSlice := mo.AppendSlice != nil && *mo.AppendSlice
+
+		var to xpt.ToFieldPathPolicy
+		switch {
+		case keepMapValues && appendSlice:
+			to = xpt.ToFieldPathPolicyMergeObjectsAppendArrays
+		case keepMapValues:
+			to = xpt.ToFieldPathPolicyMergeObjects
+		case appendSlice:
+			to = xpt.ToFieldPathPolicyForceMergeObjectsAppendArrays
+		default:
+			to = xpt.ToFieldPathPolicyForceMergeObjects
+		}
+		out.ToFieldPath = &to
+	}
+	return out
+}
+
+// convertConnectionDetail converts the given connection detail. The name and
+// type are inferred the same way Crossplane does if they are not set.
+func convertConnectionDetail(cd xapiextv1.ConnectionDetail) (xpt.ConnectionDetail, error) {
+	out := xpt.ConnectionDetail{
+		Name:                    utils.StringValue(cd.Name),
+		FromConnectionSecretKey: cd.FromConnectionSecretKey,
+		FromFieldPath:           cd.FromFieldPath,
+		Value:                   cd.Value,
+	}
+	if out.Name == "" {
+		out.Name = utils.StringValue(cd.FromConnectionSecretKey)
+	}
+
+	switch {
+	case cd.Type != nil:
+		out.Type = xpt.ConnectionDetailType(*cd.Type)
+	case cd.Value != nil:
+		out.Type = xpt.ConnectionDetailTypeFromValue
+	case cd.FromConnectionSecretKey != nil:
+		out.Type = xpt.ConnectionDetailTypeFromConnectionSecretKey
+	case cd.FromFieldPath != nil:
+		out.Type = xpt.ConnectionDetailTypeFromFieldPath
+	default:
+		return xpt.ConnectionDetail{}, errors.Errorf(errFmtUnknownConnectionDetail, out.Name)
+	}
+	return out, nil
+}
+
+// convertReadinessCheck converts the given readiness check. Only the match
+// value used by the type of the check is set.
+func convertReadinessCheck(rc xapiextv1.ReadinessCheck) xpt.ReadinessCheck {
+	out := xpt.ReadinessCheck{
+		Type: xpt.ReadinessCheckType(rc.Type),
+	}
+	if rc.FieldPath != "" {
+		out.FieldPath = &rc.FieldPath
+	}
+	switch rc.Type {
+	case xapiextv1.ReadinessCheckTypeMatchString:
+		out.MatchString = &rc.MatchString
+	case xapiextv1.ReadinessCheckTypeMatchInteger:
+		out.MatchInteger = &rc.MatchInteger
+	case xapiextv1.ReadinessCheckTypeMatchCondition:
+		if rc.MatchCondition != nil {
+			out.MatchCondition = &xpt.MatchConditionReadinessCheck{
+				Type:   rc.MatchCondition.Type,
+				Status: rc.MatchCondition.Status,
+			}
+		}
+	}
+	return out
+}
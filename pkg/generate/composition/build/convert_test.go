@@ -0,0 +1,294 @@
+package build
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	xpt "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/mproffitt/crossbuilder/pkg/generate/utils"
+)
+
+func TestConvertToPipeline(t *testing.T) {
+	base := &runtime.RawExtension{Object: &corev1.ConfigMap{}}
+	fromComposite := xapiextv1.Patch{
+		Type:          xapiextv1.PatchTypeFromCompositeFieldPath,
+		FromFieldPath: utils.StrPtr("spec.name"),
+		ToFieldPath:   utils.StrPtr("metadata.name"),
+	}
+	wantFromComposite := xpt.Patch{
+		FromFieldPath: utils.StrPtr("spec.name"),
+		ToFieldPath:   utils.StrPtr("metadata.name"),
+	}
+
+	cases := map[string]struct {
+		templates []xapiextv1.ComposedTemplate
+		patchSets []xapiextv1.PatchSet
+		env       *xapiextv1.EnvironmentConfiguration
+		want      *xpt.Resources
+		wantEnv   *xapiextv1.EnvironmentConfiguration
+	}{
+		"Resources": {
+			templates: []xapiextv1.ComposedTemplate{
+				{Name: utils.StrPtr("config"), Base: *base, Patches: []xapiextv1.Patch{fromComposite}},
+				{Base: *base},
+			},
+			want: &xpt.Resources{
+				Resources: []xpt.ComposedTemplate{
+					{
+						Name: "config",
+						Base: base,
+						Patches: []xpt.ComposedPatch{{
+							Type:  xpt.PatchTypeFromCompositeFieldPath,
+							Patch: wantFromComposite,
+						}},
+					},
+					{Name: "resource-1", Base: base},
+				},
+			},
+		},
+		"PatchSets": {
+			templates: []xapiextv1.ComposedTemplate{{
+				Name: utils.StrPtr("config"),
+				Base: *base,
+				Patches: []xapiextv1.Patch{{
+					Type:         xapiextv1.PatchTypePatchSet,
+					PatchSetName: utils.StrPtr("common"),
+				}},
+			}},
+			patchSets: []xapiextv1.PatchSet{{Name: "common", Patches: []xapiextv1.Patch{fromComposite}}},
+			want: &xpt.Resources{
+				PatchSets: []xpt.PatchSet{{
+					Name: "common",
+					Patches: []xpt.PatchSetPatch{{
+						Type:  xpt.PatchTypeFromCompositeFieldPath,
+						Patch: wantFromComposite,
+					}},
+				}},
+				Resources: []xpt.ComposedTemplate{{
+					Name: "config",
+					Base: base,
+					Patches: []xpt.ComposedPatch{{
+						Type:         xpt.PatchTypePatchSet,
+						PatchSetName: utils.StrPtr("common"),
+					}},
+				}},
+			},
+		},
+		"EnvironmentPatchesMoved": {
+			env: &xapiextv1.EnvironmentConfiguration{
+				DefaultData: map[string]extv1.JSON{"a": {Raw: []byte(`"b"`)}},
+				Patches: []xapiextv1.EnvironmentPatch{{
+					Type:          xapiextv1.PatchTypeFromCompositeFieldPath,
+					FromFieldPath: utils.StrPtr("spec.name"),
+					ToFieldPath:   utils.StrPtr("name"),
+				}},
+			},
+			want: &xpt.Resources{
+				Environment: &xpt.Environment{
+					Patches: []xpt.EnvironmentPatch{{
+						Type: xpt.PatchTypeFromCompositeFieldPath,
+						Patch: xpt.Patch{
+							FromFieldPath: utils.StrPtr("spec.name"),
+							ToFieldPath:   utils.StrPtr("name"),
+						},
+					}},
+				},
+			},
+			wantEnv: &xapiextv1.EnvironmentConfiguration{
+				DefaultData: map[string]extv1.JSON{"a": {Raw: []byte(`"b"`)}},
+			},
+		},
+		"EmptyEnvironmentDropped": {
+			env: &xapiextv1.EnvironmentConfiguration{
+				Patches: []xapiextv1.EnvironmentPatch{{
+					Type:          xapiextv1.PatchTypeToCompositeFieldPath,
+					FromFieldPath: utils.StrPtr("name"),
+					ToFieldPath:   utils.StrPtr("spec.name"),
+				}},
+			},
+			want: &xpt.Resources{
+				Environment: &xpt.Environment{
+					Patches: []xpt.EnvironmentPatch{{
+						Type: xpt.PatchTypeToCompositeFieldPath,
+						Patch: xpt.Patch{
+							FromFieldPath: utils.StrPtr("name"),
+							ToFieldPath:   utils.StrPtr("spec.name"),
+						},
+					}},
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			steps, env, err := convertToPipeline(tc.templates, tc.patchSets, tc.env)
+			if err != nil {
+				t.Fatalf("convertToPipeline(): %v", err)
+			}
+			if len(steps) != 2 || steps[0].Step != FunctionPatchAndTransform || steps[1].Step != FunctionAutoReady {
+				t.Fatalf("convertToPipeline() steps = %+v, want patch-and-transform followed by auto-ready", steps)
+			}
+			if steps[1].Input != nil {
+				t.Errorf("auto-ready step has input %+v", steps[1].Input)
+			}
+
+			tc.want.SetGroupVersionKind(ResourcesGroupVersionKind)
+			if got := steps[0].Input.Object; !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("convertToPipeline() input = %+v, want %+v", got, tc.want)
+			}
+			if !reflect.DeepEqual(env, tc.wantEnv) {
+				t.Errorf("convertToPipeline() environment = %+v, want %+v", env, tc.wantEnv)
+			}
+		})
+	}
+}
+
+func TestToPatchPolicy(t *testing.T) {
+	policy := func(to xpt.ToFieldPathPolicy) *xpt.PatchPolicy {
+		return &xpt.PatchPolicy{ToFieldPath: &to}
+	}
+	required := xapiextv1.FromFieldPathPolicyRequired
+	wantRequired := xpt.FromFieldPathPolicyRequired
+
+	cases := map[string]struct {
+		in   *xapiextv1.PatchPolicy
+		want *xpt.PatchPolicy
+	}{
+		"Nil": {},
+		"FromFieldPath": {
+			in:   &xapiextv1.PatchPolicy{FromFieldPath: &required},
+			want: &xpt.PatchPolicy{FromFieldPath: &wantRequired},
+		},
+		"MergeOptionsEmpty": {
+			in:   &xapiextv1.PatchPolicy{MergeOptions: &xpv1.MergeOptions{}},
+			want: policy(xpt.ToFieldPathPolicyForceMergeObjects),
+		},
+		"KeepMapValues": {
+			in:   &xapiextv1.PatchPolicy{MergeOptions: &xpv1.MergeOptions{KeepMapValues: utils.BoolPtr(true)}},
+			want: policy(xpt.ToFieldPathPolicyMergeObjects),
+		},
+		"AppendSlice": {
+			in:   &xapiextv1.PatchPolicy{MergeOptions: &xpv1.MergeOptions{AppendSlice: utils.BoolPtr(true)}},
+			want: policy(xpt.ToFieldPathPolicyForceMergeObjectsAppendArrays),
+		},
+		"KeepMapValuesAndAppendSlice": {
+			in: &xapiextv1.PatchPolicy{MergeOptions: &xpv1.MergeOptions{
+				KeepMapValues: utils.BoolPtr(true),
+				AppendSlice:   utils.BoolPtr(true),
+			}},
+			want: policy(xpt.ToFieldPathPolicyMergeObjectsAppendArrays),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := toPatchPolicy(tc.in); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("toPatchPolicy() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConvertConnectionDetail(t *testing.T) {
+	fromValue := xapiextv1.ConnectionDetailTypeFromValue
+
+	cases := map[string]struct {
+		in      xapiextv1.ConnectionDetail
+		want    xpt.ConnectionDetail
+		wantErr string
+	}{
+		"ExplicitType": {
+			in: xapiextv1.ConnectionDetail{Name: utils.StrPtr("url"), Type: &fromValue, Value: utils.StrPtr("x")},
+			want: xpt.ConnectionDetail{
+				Name:  "url",
+				Type:  xpt.ConnectionDetailTypeFromValue,
+				Value: utils.StrPtr("x"),
+			},
+		},
+		"InferredFromValue": {
+			in:   xapiextv1.ConnectionDetail{Name: utils.StrPtr("url"), Value: utils.StrPtr("x")},
+			want: xpt.ConnectionDetail{Name: "url", Type: xpt.ConnectionDetailTypeFromValue, Value: utils.StrPtr("x")},
+		},
+		"InferredFromConnectionSecretKeyAndNamed": {
+			in: xapiextv1.ConnectionDetail{FromConnectionSecretKey: utils.StrPtr("password")},
+			want: xpt.ConnectionDetail{
+				Name:                    "password",
+				Type:                    xpt.ConnectionDetailTypeFromConnectionSecretKey,
+				FromConnectionSecretKey: utils.StrPtr("password"),
+			},
+		},
+		"InferredFromFieldPath": {
+			in: xapiextv1.ConnectionDetail{Name: utils.StrPtr("host"), FromFieldPath: utils.StrPtr("status.host")},
+			want: xpt.ConnectionDetail{
+				Name:          "host",
+				Type:          xpt.ConnectionDetailTypeFromFieldPath,
+				FromFieldPath: utils.StrPtr("status.host"),
+			},
+		},
+		"Unknown": {
+			in:      xapiextv1.ConnectionDetail{Name: utils.StrPtr("what")},
+			wantErr: `cannot infer the type of connection detail "what"`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := convertConnectionDetail(tc.in)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("convertConnectionDetail(): %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("convertConnectionDetail() = %v, want error containing %q", err, tc.wantErr)
+			case !reflect.DeepEqual(got, tc.want):
+				t.Errorf("convertConnectionDetail() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConvertReadinessCheck(t *testing.T) {
+	cases := map[string]struct {
+		in   xapiextv1.ReadinessCheck
+		want xpt.ReadinessCheck
+	}{
+		"MatchInteger": {
+			in: xapiextv1.ReadinessCheck{Type: xapiextv1.ReadinessCheckTypeMatchInteger, FieldPath: "status.n", MatchInteger: 2},
+			want: xpt.ReadinessCheck{
+				Type:         xpt.ReadinessCheckTypeMatchInteger,
+				FieldPath:    utils.StrPtr("status.n"),
+				MatchInteger: utils.Int64Ptr(2),
+			},
+		},
+		"MatchStringOnly": {
+			in: xapiextv1.ReadinessCheck{Type: xapiextv1.ReadinessCheckTypeMatchString, FieldPath: "status.s", MatchString: "ok", MatchInteger: 1},
+			want: xpt.ReadinessCheck{
+				Type:        xpt.ReadinessCheckTypeMatchString,
+				FieldPath:   utils.StrPtr("status.s"),
+				MatchString: utils.StrPtr("ok"),
+			},
+		},
+		"MatchCondition": {
+			in: ReadyWhenConditionTrue(xpv1.TypeReady),
+			want: xpt.ReadinessCheck{
+				Type:           xpt.ReadinessCheckTypeMatchCondition,
+				MatchCondition: &xpt.MatchConditionReadinessCheck{Type: xpv1.TypeReady, Status: corev1.ConditionTrue},
+			},
+		},
+		"None": {
+			in:   ReadyOnCreation(),
+			want: xpt.ReadinessCheck{Type: xpt.ReadinessCheckTypeNone},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := convertReadinessCheck(tc.in); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("convertReadinessCheck() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package build
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	errDecodeCRD          = "cannot decode custom resource definition"
+	errInvalidBase        = "base does not match the schema of its custom resource definition"
+	errFmtUnknownField    = "%s: unknown field"
+	errFmtSchemaValueType = "%s: expected a value of type %s but got %s"
+	errConvertBase        = "cannot convert base to unstructured content"
+
+	errFmtInvalidResourceBase = "invalid base for resource %q"
+)
+
+// CustomResourceDefinitionGroupVersionKind is the GroupVersionKind of
+// CustomResourceDefinitions.
+var CustomResourceDefinitionGroupVersionKind = extv1.SchemeGroupVersion.WithKind("CustomResourceDefinition")
+
+// LoadCRDs reads all CustomResourceDefinitions from the YAML files in the
+// given directory and its subdirectories, for example the CRDs vendored from
+// providers. Other objects in these files are ignored, as is a directory that
+// does not exist.
+func LoadCRDs(dir string) ([]extv1.CustomResourceDefinition, error) {
+	manifests, err := readManifests(dir, CustomResourceDefinitionGroupVersionKind)
+	if err != nil {
+		return nil, err
+	}
+	crds := make([]extv1.CustomResourceDefinition, len(manifests))
+	for i, m := range manifests {
+		if err := json.Unmarshal(m, &crds[i]); err != nil {
+			return nil, errors.Wrap(err, errDecodeCRD)
+		}
+	}
+	return crds, nil
+}
+
+// openAPISchema is the OpenAPI v3 schema of a custom resource. Field paths
+// of objects without Go type are validated against it.
+type openAPISchema struct {
+	gvk    schema.GroupVersionKind
+	schema *extv1.JSONSchemaProps
+}
+
+// openAPISchemaFor returns the schema of the given kind from the given CRDs
+// or nil if none of them serves it.
+func openAPISchemaFor(crds []extv1.CustomResourceDefinition, gvk schema.GroupVersionKind) *openAPISchema {
+	for _, crd := range crds {
+		if crd.Spec.Group != gvk.Group || crd.Spec.Names.Kind != gvk.Kind {
+			continue
+		}
+		for _, v := range crd.Spec.Versions {
+			if v.Name == gvk.Version && v.Schema != nil && v.Schema.OpenAPIV3Schema != nil {
+				return &openAPISchema{gvk: gvk, schema: v.Schema.OpenAPIV3Schema}
+			}
+		}
+	}
+	return nil
+}
+
+// validationTarget returns what field paths of the given object are validated
+// against: the Go type of typed objects, or the schema of the CRD serving the
+// kind of unstructured objects. Objects that cannot be validated are
+// returned as they are.
+func validationTarget(crds []extv1.CustomResourceDefinition, obj runtime.Object) interface{} {
+	if obj == nil || isTypedObject(obj) {
+		return obj
+	}
+	if s := openAPISchemaFor(crds, obj.GetObjectKind().GroupVersionKind()); s != nil {
+		return s
+	}
+	return obj
+}
+
+// canValidate returns true if field paths can be validated against the given
+// validation target.
+func canValidate(target interface{}) bool {
+	switch t := target.(type) {
+	case *openAPISchema:
+		return true
+	case runtime.Object:
+		return isTypedObject(t)
+	}
+	return false
+}
+
+// rootFields are the fields of the schema root that are served by the API
+// server rather than described by the schema.
+var rootFields = map[string]reflect.Type{
+	"apiVersion": reflect.TypeOf(""),
+	"kind":       reflect.TypeOf(""),
+	"metadata":   reflect.TypeOf(metav1.ObjectMeta{}),
+}
+
+// pathKind returns the kind of value the field the given segments refer to
+// holds. Paths into metadata are validated against its Go type.
+func (s *openAPISchema) pathKind(segments fieldpath.Segments) (valueKind, error) {
+	if t, ok := rootFields[segments[0].Field]; ok && segments[0].Type == fieldpath.SegmentField {
+		t, err := validatePath(reflect.New(t).Interface(), segments[1:])
+		if err != nil {
+			return "", err
+		}
+		return kindOf(t), nil
+	}
+
+	current := s.schema
+	for i, segment := range segments {
+		if acceptsAnySchema(current) {
+			return valueKindAny, nil // any sub path is valid
+		}
+		if current.XIntOrString {
+			return "", errors.Errorf(errFmtLeafType, schemaKind(current), segments[i:])
+		}
+
+		switch segment.Type {
+		case fieldpath.SegmentField:
+			next, ok := current.Properties[segment.Field]
+			switch {
+			case ok:
+				current = &next
+			case current.AdditionalProperties != nil && current.AdditionalProperties.Schema != nil:
+				current = current.AdditionalProperties.Schema
+			case current.AdditionalProperties != nil && current.AdditionalProperties.Allows:
+				return valueKindAny, nil
+			case current.Type != "" && current.Type != "object":
+				return "", errors.Wrap(errors.Errorf(errFmtNotStruct, current.Type), errGetStructField)
+			default:
+				return "", errors.Wrap(errors.Errorf(errFmtFieldNotFound, segment.Field), errGetStructField)
+			}
+		case fieldpath.SegmentIndex:
+			switch {
+			case current.Type == "array" && current.Items != nil && current.Items.Schema != nil:
+				current = current.Items.Schema
+			case current.Type == "array":
+				return valueKindAny, nil
+			case current.AdditionalProperties != nil && current.AdditionalProperties.Schema != nil:
+				current = current.AdditionalProperties.Schema // numeric map key
+			default:
+				return "", errors.Errorf(errFmtNotArrayOrSlice, current.Type)
+			}
+		}
+	}
+	return schemaKind(current), nil
+}
+
+// acceptsAnySchema returns true for schemas of fields holding arbitrary JSON.
+// Objects without any properties are treated the same, as their content is
+// not described.
+func acceptsAnySchema(s *extv1.JSONSchemaProps) bool {
+	if s.XPreserveUnknownFields != nil && *s.XPreserveUnknownFields && len(s.Properties) == 0 {
+		return true
+	}
+	if s.XEmbeddedResource {
+		return true
+	}
+	return (s.Type == "" || s.Type == "object") && len(s.Properties) == 0 && s.AdditionalProperties == nil && !s.XIntOrString
+}
+
+// schemaKind returns the kind of value described by the given schema.
+func schemaKind(s *extv1.JSONSchemaProps) valueKind {
+	if s.XIntOrString {
+		return valueKindIntOrString
+	}
+	switch s.Type {
+	case "string":
+		return valueKindString
+	case "integer":
+		return valueKindInteger
+	case "number":
+		return valueKindNumber
+	case "boolean":
+		return valueKindBoolean
+	case "object":
+		return valueKindObject
+	case "array":
+		return valueKindArray
+	}
+	return valueKindAny
+}
+
+// validateObject checks the given object for fields unknown to the schema
+// and values of the wrong type. Metadata is not validated.
+func (s *openAPISchema) validateObject(obj runtime.Object) error {
+	var content map[string]interface{}
+	if u, ok := obj.(runtime.Unstructured); ok {
+		content = u.UnstructuredContent()
+	} else {
+		var err error
+		if content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj); err != nil {
+			return errors.Wrap(err, errConvertBase)
+		}
+	}
+
+	for _, k := range sortedFields(content) {
+		if _, ok := rootFields[k]; ok {
+			continue
+		}
+		if err := validateSchemaValue(content[k], s.schema, fieldpath.Segments{fieldpath.Field(k)}); err != nil {
+			return errors.Wrap(err, errInvalidBase)
+		}
+	}
+	return nil
+}
+
+// validateSchemaValue validates the value found at the given path against
+// the schema of its parent object.
+func validateSchemaValue(value interface{}, parent *extv1.JSONSchemaProps, path fieldpath.Segments) error {
+	field := path[len(path)-1].Field
+	s, ok := parent.Properties[field]
+	switch {
+	case ok:
+	case parent.AdditionalProperties != nil && parent.AdditionalProperties.Schema != nil:
+		s = *parent.AdditionalProperties.Schema
+	case parent.AdditionalProperties != nil && parent.AdditionalProperties.Allows, acceptsAnySchema(parent):
+		return nil
+	default:
+		return errors.Errorf(errFmtUnknownField, path)
+	}
+	return validateSchemaType(value, &s, path)
+}
+
+// validateSchemaType validates the given value and everything below it
+// against the given schema.
+func validateSchemaType(value interface{}, s *extv1.JSONSchemaProps, path fieldpath.Segments) error {
+	if value == nil || acceptsAnySchema(s) {
+		return nil
+	}
+	expected, actual := schemaKind(s), kindOfValue(value)
+	if !isAssignable(actual, expected) {
+		return errors.Errorf(errFmtSchemaValueType, path, expected, actual)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedFields(v) {
+			if err := validateSchemaValue(v[k], s, append(path, fieldpath.Field(k))); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if s.Items == nil || s.Items.Schema == nil {
+			return nil
+		}
+		for i, item := range v {
+			segment := fieldpath.Segment{Type: fieldpath.SegmentIndex, Index: uint(i)}
+			if err := validateSchemaType(item, s.Items.Schema, append(path, segment)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// kindOfValue returns the kind of the given unstructured value.
+func kindOfValue(value interface{}) valueKind {
+	switch v := value.(type) {
+	case string:
+		return valueKindString
+	case bool:
+		return valueKindBoolean
+	case int, int32, int64:
+		return valueKindInteger
+	case float64:
+		if v == float64(int64(v)) {
+			return valueKindInteger
+		}
+		return valueKindNumber
+	case map[string]interface{}:
+		return valueKindObject
+	case []interface{}:
+		return valueKindArray
+	}
+	return valueKindAny
+}
+
+func sortedFields(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// unstructuredBase returns the given raw extension as unstructured object if
+// it holds no object but raw content, so its kind can be looked up.
+func unstructuredBase(raw *runtime.RawExtension) runtime.Object {
+	if raw == nil {
+		return nil
+	}
+	if raw.Object != nil || len(raw.Raw) == 0 {
+		return raw.Object
+	}
+	u := &unstructured.Unstructured{}
+	if err := u.UnmarshalJSON(raw.Raw); err != nil {
+		return nil
+	}
+	return u
+}
@@ -0,0 +1,329 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+const testSchema = `
+type: object
+properties:
+  spec:
+    type: object
+    properties:
+      forProvider:
+        type: object
+        properties:
+          name:
+            type: string
+          size:
+            type: integer
+          ratio:
+            type: number
+          enabled:
+            type: boolean
+          port:
+            x-kubernetes-int-or-string: true
+          tags:
+            type: object
+            additionalProperties:
+              type: string
+          rules:
+            type: array
+            items:
+              type: object
+              properties:
+                cidr:
+                  type: string
+          values:
+            type: array
+          config:
+            type: object
+            x-kubernetes-preserve-unknown-fields: true
+          labels:
+            type: object
+            additionalProperties: true
+  status:
+    type: object
+    properties:
+      atProvider:
+        type: object
+        properties:
+          id:
+            type: string
+`
+
+var testGVK = schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "Thing"}
+
+func testOpenAPISchema(t *testing.T) *openAPISchema {
+	t.Helper()
+	s := &extv1.JSONSchemaProps{}
+	if err := yaml.Unmarshal([]byte(testSchema), s); err != nil {
+		t.Fatal(err)
+	}
+	return &openAPISchema{gvk: testGVK, schema: s}
+}
+
+func TestOpenAPISchemaPathKind(t *testing.T) {
+	cases := map[string]struct {
+		path    string
+		want    valueKind
+		wantErr string
+	}{
+		"String":             {path: "spec.forProvider.name", want: valueKindString},
+		"Integer":            {path: "spec.forProvider.size", want: valueKindInteger},
+		"Number":             {path: "spec.forProvider.ratio", want: valueKindNumber},
+		"Boolean":            {path: "spec.forProvider.enabled", want: valueKindBoolean},
+		"IntOrString":        {path: "spec.forProvider.port", want: valueKindIntOrString},
+		"Object":             {path: "spec.forProvider", want: valueKindObject},
+		"AdditionalProperty": {path: "spec.forProvider.tags[team]", want: valueKindString},
+		"ArrayItem":          {path: "spec.forProvider.rules[0].cidr", want: valueKindString},
+		"UntypedArrayItem":   {path: "spec.forProvider.values[3].anything", want: valueKindAny},
+		"PreserveUnknown":    {path: "spec.forProvider.config.any.thing", want: valueKindAny},
+		"AllowsAny":          {path: "spec.forProvider.labels.app", want: valueKindAny},
+		"Metadata":           {path: "metadata.labels[app]", want: valueKindString},
+		"Kind":               {path: "kind", want: valueKindString},
+		"UnknownField": {
+			path:    "spec.forProvider.nope",
+			wantErr: "no field with JSON key 'nope'",
+		},
+		"UnknownMetadataField": {
+			path:    "metadata.nope",
+			wantErr: "no field with JSON key 'nope'",
+		},
+		"FieldOfScalar": {
+			path:    "spec.forProvider.name.first",
+			wantErr: "expected struct type, but got string",
+		},
+		"IndexIntoObject": {
+			path:    "spec.forProvider[0]",
+			wantErr: "expected array or slice type but got object",
+		},
+		"FieldOfIntOrString": {
+			path:    "spec.forProvider.port.value",
+			wantErr: "int-or-string has no field value",
+		},
+	}
+	s := testOpenAPISchema(t)
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			segments, err := fieldpath.Parse(tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.pathKind(segments)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("pathKind(%q): %v", tc.path, err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("pathKind(%q) = %v, want error containing %q", tc.path, err, tc.wantErr)
+			case got != tc.want:
+				t.Errorf("pathKind(%q) = %s, want %s", tc.path, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOpenAPISchemaValidateObject(t *testing.T) {
+	cases := map[string]struct {
+		content map[string]interface{}
+		wantErr string
+	}{
+		"Valid": {
+			content: map[string]interface{}{
+				"metadata": map[string]interface{}{"anything": "goes"},
+				"spec": map[string]interface{}{
+					"forProvider": map[string]interface{}{
+						"name":    "a",
+						"size":    int64(2),
+						"ratio":   float64(2),
+						"enabled": true,
+						"port":    "http",
+						"tags":    map[string]interface{}{"team": "core"},
+						"rules":   []interface{}{map[string]interface{}{"cidr": "10.0.0.0/8"}},
+						"config":  map[string]interface{}{"any": []interface{}{1, "x"}},
+						"labels":  map[string]interface{}{"app": 1},
+					},
+				},
+			},
+		},
+		"UnknownRootField": {
+			content: map[string]interface{}{"nope": "x"},
+			wantErr: "nope: unknown field",
+		},
+		"UnknownNestedField": {
+			content: map[string]interface{}{
+				"spec": map[string]interface{}{"forProvider": map[string]interface{}{"nope": "x"}},
+			},
+			wantErr: "spec.forProvider.nope: unknown field",
+		},
+		"WrongType": {
+			content: map[string]interface{}{
+				"spec": map[string]interface{}{"forProvider": map[string]interface{}{"size": "big"}},
+			},
+			wantErr: "spec.forProvider.size: expected a value of type integer but got string",
+		},
+		"NumberForInteger": {
+			content: map[string]interface{}{
+				"spec": map[string]interface{}{"forProvider": map[string]interface{}{"size": 1.5}},
+			},
+			wantErr: "expected a value of type integer but got number",
+		},
+		"WrongAdditionalPropertyType": {
+			content: map[string]interface{}{
+				"spec": map[string]interface{}{"forProvider": map[string]interface{}{
+					"tags": map[string]interface{}{"team": true},
+				}},
+			},
+			wantErr: "spec.forProvider.tags.team: expected a value of type string but got boolean",
+		},
+		"WrongArrayItem": {
+			content: map[string]interface{}{
+				"spec": map[string]interface{}{"forProvider": map[string]interface{}{
+					"rules": []interface{}{map[string]interface{}{"cidr": "a"}, map[string]interface{}{"cidr": 1}},
+				}},
+			},
+			wantErr: "spec.forProvider.rules[1].cidr: expected a value of type string but got integer",
+		},
+		"Null": {
+			content: map[string]interface{}{
+				"spec": map[string]interface{}{"forProvider": map[string]interface{}{"name": nil}},
+			},
+		},
+	}
+	s := testOpenAPISchema(t)
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := s.validateObject(&unstructured.Unstructured{Object: tc.content})
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("validateObject(): %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("validateObject() = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidationTarget(t *testing.T) {
+	s := testOpenAPISchema(t)
+	crds := []extv1.CustomResourceDefinition{{
+		Spec: extv1.CustomResourceDefinitionSpec{
+			Group: testGVK.Group,
+			Names: extv1.CustomResourceDefinitionNames{Kind: testGVK.Kind},
+			Versions: []extv1.CustomResourceDefinitionVersion{
+				{Name: "v1beta1"},
+				{Name: testGVK.Version, Schema: &extv1.CustomResourceValidation{OpenAPIV3Schema: s.schema}},
+			},
+		},
+	}}
+	unstructuredOf := func(gvk schema.GroupVersionKind) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(gvk)
+		return u
+	}
+
+	cases := map[string]struct {
+		obj          runtime.Object
+		wantSchema   bool
+		wantValidate bool
+	}{
+		"Typed": {
+			obj:          &corev1.Pod{},
+			wantValidate: true,
+		},
+		"UnstructuredWithCRD": {
+			obj:          unstructuredOf(testGVK),
+			wantSchema:   true,
+			wantValidate: true,
+		},
+		"UnstructuredOtherKind": {
+			obj: unstructuredOf(testGVK.GroupVersion().WithKind("Other")),
+		},
+		"UnstructuredVersionWithoutSchema": {
+			obj: unstructuredOf(schema.GroupVersionKind{Group: testGVK.Group, Version: "v1beta1", Kind: testGVK.Kind}),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			target := validationTarget(crds, tc.obj)
+			if _, ok := target.(*openAPISchema); ok != tc.wantSchema {
+				t.Errorf("validationTarget() = %T, want schema %t", target, tc.wantSchema)
+			}
+			if got := canValidate(target); got != tc.wantValidate {
+				t.Errorf("canValidate() = %t, want %t", got, tc.wantValidate)
+			}
+		})
+	}
+}
+
+func TestUnstructuredBase(t *testing.T) {
+	pod := &corev1.Pod{}
+	cases := map[string]struct {
+		raw     *runtime.RawExtension
+		wantGVK schema.GroupVersionKind
+		want    runtime.Object
+	}{
+		"Nil": {},
+		"Object": {
+			raw:  &runtime.RawExtension{Object: pod},
+			want: pod,
+		},
+		"Raw": {
+			raw:     &runtime.RawExtension{Raw: []byte(`{"apiVersion": "example.org/v1", "kind": "Thing"}`)},
+			wantGVK: testGVK,
+		},
+		"InvalidRaw": {
+			raw: &runtime.RawExtension{Raw: []byte(`not json`)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := unstructuredBase(tc.raw)
+			if tc.wantGVK.Empty() {
+				if got != tc.want {
+					t.Errorf("unstructuredBase() = %v, want %v", got, tc.want)
+				}
+				return
+			}
+			u, ok := got.(*unstructured.Unstructured)
+			if !ok {
+				t.Fatalf("unstructuredBase() = %T, want *unstructured.Unstructured", got)
+			}
+			if u.GroupVersionKind() != tc.wantGVK {
+				t.Errorf("unstructuredBase() kind = %s, want %s", u.GroupVersionKind(), tc.wantGVK)
+			}
+		})
+	}
+}
+
+func TestLoadCRDs(t *testing.T) {
+	dir := t.TempDir()
+	content := "apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: things.example.org\n" +
+		"spec:\n  group: example.org\n  names:\n    kind: Thing\n  versions:\n  - name: v1\n    schema:\n      openAPIV3Schema:\n" +
+		"        type: object\n---\n" + xrd("a")
+	if err := os.WriteFile(filepath.Join(dir, "crds.yaml"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	crds, err := LoadCRDs(dir)
+	if err != nil {
+		t.Fatalf("LoadCRDs(): %v", err)
+	}
+	if len(crds) != 1 || crds[0].GetName() != "things.example.org" {
+		t.Fatalf("LoadCRDs() = %+v, want the things.example.org CRD only", crds)
+	}
+	if openAPISchemaFor(crds, testGVK) == nil {
+		t.Errorf("openAPISchemaFor(%s) = nil, want the schema of the loaded CRD", testGVK)
+	}
+}
@@ -6,6 +6,12 @@ import (
 
 	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
 	"github.com/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 const (
@@ -15,63 +21,151 @@ const (
 	errFmtNotArrayOrSlice  = "expected array or slice type but got %s"
 	errFmtFieldNotFound    = "no field with JSON key '%s'"
 	errGetStructField      = "cannot get field"
-	errMapTypeNotSupported = "static path validation is not supported for maps"
+	errFmtLeafType         = "%s has no field %s"
 	errFmtInvalidFieldPath = "invalid field path '%s'"
 )
 
+// wildcard is the segment of a registered path matching any field or index.
+const wildcard = "*"
+
+var (
+	// leafTypes are types with a custom JSON serialization that are
+	// serialized as scalar values, mapped to the kind of value they hold.
+	leafTypes = map[reflect.Type]valueKind{
+		reflect.TypeOf(resource.Quantity{}):  valueKindQuantity,
+		reflect.TypeOf(intstr.IntOrString{}): valueKindIntOrString,
+		reflect.TypeOf(metav1.Time{}):        valueKindString,
+		reflect.TypeOf(metav1.MicroTime{}):   valueKindString,
+		reflect.TypeOf(metav1.Duration{}):    valueKindString,
+	}
+
+	// anyPathTypes are types holding arbitrary JSON.
+	anyPathTypes = map[reflect.Type]bool{
+		reflect.TypeOf(runtime.RawExtension{}):      true,
+		reflect.TypeOf(extv1.JSON{}):                true,
+		reflect.TypeOf(unstructured.Unstructured{}): true,
+		reflect.TypeOf(map[string]interface{}{}):    true,
+		reflect.TypeOf([]interface{}{}):             true,
+	}
+)
+
 // ValidateFieldPath checks if the JSON path exists for the given object.
 func ValidateFieldPath(obj interface{}, path string, knownPaths []fieldpath.Segments) error {
+	_, err := fieldPathKind(obj, path, knownPaths)
+	return err
+}
+
+// fieldPathKind returns the kind of value the field the JSON path refers to
+// in the given object holds. The kind is any for registered paths.
+func fieldPathKind(obj interface{}, path string, knownPaths []fieldpath.Segments) (valueKind, error) {
 	segments, err := fieldpath.Parse(path)
 	if err != nil {
-		return errors.Wrap(err, errParseFieldPath)
+		return "", errors.Wrap(err, errParseFieldPath)
 	}
 	if len(segments) == 0 {
-		return errors.New(errEmptyPath)
+		return "", errors.New(errEmptyPath)
 	}
+	kind, err := validateSegments(obj, segments, knownPaths)
+	return kind, errors.Wrap(err, path)
+}
+
+// validateSegments returns the kind of value the field the given segments
+// refer to holds. Registered paths are always valid and hold any kind.
+func validateSegments(obj interface{}, segments fieldpath.Segments, knownPaths []fieldpath.Segments) (valueKind, error) {
 	if isKnownPath(segments, knownPaths) {
-		return nil // path is a registered path
+		return valueKindAny, nil // path is a registered path
 	}
+	return pathKind(obj, segments)
+}
 
-	return errors.Wrap(validatePath(obj, segments), path)
+// pathKind returns the kind of value the field the given segments refer to
+// holds, read from the OpenAPI schema or the Go type of the object.
+func pathKind(obj interface{}, segments fieldpath.Segments) (valueKind, error) {
+	if s, ok := obj.(*openAPISchema); ok {
+		return s.pathKind(segments)
+	}
+	t, err := validatePath(obj, segments)
+	if err != nil {
+		return "", err
+	}
+	return kindOf(t), nil
 }
 
-func validatePath(obj interface{}, segments fieldpath.Segments) error {
+// validatePath returns the type of the field the given segments refer to.
+// The type is nil if the path points into a field holding arbitrary JSON.
+func validatePath(obj interface{}, segments fieldpath.Segments) (reflect.Type, error) {
 	current := reflect.TypeOf(obj)
-	for _, segment := range segments {
-		if current.Kind() == reflect.Ptr {
+	for i, segment := range segments {
+		for current.Kind() == reflect.Ptr {
 			current = current.Elem()
 		}
+		if acceptsAnyPath(current) {
+			return nil, nil // any sub path is valid
+		}
+		if _, ok := leafTypes[current]; ok || hasCustomJSON(current) {
+			return nil, errors.Errorf(errFmtLeafType, current, segments[i:])
+		}
 
 		switch segment.Type {
 		case fieldpath.SegmentField:
 			var err error
 			current, err = getObjectField(current, segment.Field)
 			if err != nil {
-				return errors.Wrap(err, errGetStructField)
+				return nil, errors.Wrap(err, errGetStructField)
 			}
 		case fieldpath.SegmentIndex:
+			if current.Kind() == reflect.Map {
+				current = current.Elem() // numeric map key
+				continue
+			}
 			if current.Kind() != reflect.Array && current.Kind() != reflect.Slice {
-				return errors.Errorf(errFmtNotArrayOrSlice, current.Kind())
+				return nil, errors.Errorf(errFmtNotArrayOrSlice, current.Kind())
 			}
 			current = current.Elem()
 		}
 	}
-	return nil // Path exists
+	return current, nil // Path exists
+}
+
+// acceptsAnyPath returns true for types holding arbitrary JSON, such as
+// fields preserving unknown fields.
+func acceptsAnyPath(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface || anyPathTypes[t]
+}
+
+// hasCustomJSON returns true for types with a custom JSON serialization.
+// Unless listed in anyPathTypes, their structure is not known, so they are
+// treated as leaves.
+func hasCustomJSON(t reflect.Type) bool {
+	return t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType)
 }
 
+// isKnownPath returns true if the path matches one of the registered paths.
 func isKnownPath(path fieldpath.Segments, knownPaths []fieldpath.Segments) bool {
 	for _, known := range knownPaths {
-		if len(path) != len(known) {
+		if matchesPath(path, known) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPath returns true if the path equals the known path or lies below
+// it, so registering a path registers the whole subtree. Wildcard segments,
+// such as tags[*] or tags.*, match any single field or index.
+func matchesPath(path, known fieldpath.Segments) bool {
+	if len(path) < len(known) {
+		return false
+	}
+	for i, k := range known {
+		if k.Type == fieldpath.SegmentField && k.Field == wildcard {
 			continue
 		}
-		for i, knownSeg := range known {
-			if path[i] != knownSeg {
-				continue
-			}
+		if path[i] != k {
+			return false
 		}
-		return true
 	}
-	return false
+	return true
 }
 
 func parseFieldPaths(paths []string) ([]fieldpath.Segments, error) {
@@ -88,7 +182,7 @@ func parseFieldPaths(paths []string) ([]fieldpath.Segments, error) {
 
 func getObjectField(obj reflect.Type, jsonKey string) (reflect.Type, error) {
 	if obj.Kind() == reflect.Map {
-		return nil, errors.New(errMapTypeNotSupported)
+		return obj.Elem(), nil
 	}
 	if obj.Kind() != reflect.Struct {
 		return nil, errors.Errorf(errFmtNotStruct, obj.Kind())
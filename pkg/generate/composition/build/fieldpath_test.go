@@ -0,0 +1,168 @@
+package build
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestMatchesPath(t *testing.T) {
+	cases := map[string]struct {
+		path  string
+		known string
+		want  bool
+	}{
+		"Equal":              {path: "spec.name", known: "spec.name", want: true},
+		"Subtree":            {path: "spec.name.first", known: "spec.name", want: true},
+		"Parent":             {path: "spec", known: "spec.name", want: false},
+		"Sibling":            {path: "spec.names", known: "spec.name", want: false},
+		"Index":              {path: "spec.tags[1]", known: "spec.tags[1]", want: true},
+		"OtherIndex":         {path: "spec.tags[0]", known: "spec.tags[1]", want: false},
+		"FieldWildcard":      {path: "spec.a.name", known: "spec.*.name", want: true},
+		"IndexWildcard":      {path: "spec.tags[3].key", known: "spec.tags[*].key", want: true},
+		"WildcardMatchesKey": {path: "spec.tags[team]", known: "spec.tags[*]", want: true},
+		"WildcardOneSegment": {path: "spec.tags", known: "spec.tags[*]", want: false},
+		"WildcardNextDiffer": {path: "spec.a.other", known: "spec.*.name", want: false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			path, err := fieldpath.Parse(tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			known, err := fieldpath.Parse(tc.known)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matchesPath(path, known); got != tc.want {
+				t.Errorf("matchesPath(%q, %q) = %t, want %t", tc.path, tc.known, got, tc.want)
+			}
+		})
+	}
+}
+
+// customJSON is serialized by its own MarshalJSON rather than by its fields.
+type customJSON struct {
+	Value string `json:"value"`
+}
+
+func (c customJSON) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Value)
+}
+
+type customJSONHolder struct {
+	Custom customJSON `json:"custom"`
+}
+
+func TestFieldPathKind(t *testing.T) {
+	cases := map[string]struct {
+		obj     interface{}
+		path    string
+		known   []string
+		want    valueKind
+		wantErr string
+	}{
+		"String": {
+			path: "spec.nodeName",
+			want: valueKindString,
+		},
+		"Inline": {
+			path: "metadata.name",
+			want: valueKindString,
+		},
+		"SliceIndex": {
+			path: "spec.containers[0].image",
+			want: valueKindString,
+		},
+		"MapKey": {
+			path: "metadata.labels[app]",
+			want: valueKindString,
+		},
+		"Integer": {
+			path: "spec.containers[0].ports[0].containerPort",
+			want: valueKindInteger,
+		},
+		"Quantity": {
+			path: "spec.overhead[cpu]",
+			want: valueKindQuantity,
+		},
+		"Object": {
+			path: "spec.securityContext",
+			want: valueKindObject,
+		},
+		"Array": {
+			path: "spec.containers",
+			want: valueKindArray,
+		},
+		"UnknownField": {
+			path:    "spec.nope",
+			wantErr: "no field with JSON key 'nope'",
+		},
+		"IndexIntoStruct": {
+			path:    "spec[0]",
+			wantErr: "expected array or slice type but got struct",
+		},
+		"FieldOfLeaf": {
+			path:    "spec.overhead[cpu].value",
+			wantErr: "resource.Quantity has no field value",
+		},
+		"Empty": {
+			path:    "",
+			wantErr: errEmptyPath,
+		},
+		"RegisteredPath": {
+			path:  "spec.nope",
+			known: []string{"spec.nope"},
+			want:  valueKindAny,
+		},
+		"RegisteredSubtree": {
+			path:  "spec.extra.deep.field",
+			known: []string{"spec.extra"},
+			want:  valueKindAny,
+		},
+		"RegisteredWildcard": {
+			path:  "spec.containers[2].extra",
+			known: []string{"spec.containers[*].extra"},
+			want:  valueKindAny,
+		},
+		"RegisteredPathShortCircuits": {
+			path:  "spec.nodeName",
+			known: []string{"spec"},
+			want:  valueKindAny,
+		},
+		"CustomJSON": {
+			obj:  &customJSONHolder{},
+			path: "custom",
+			want: valueKindAny,
+		},
+		"FieldOfCustomJSON": {
+			obj:     &customJSONHolder{},
+			path:    "custom.value",
+			wantErr: "build.customJSON has no field value",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			known, err := parseFieldPaths(tc.known)
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj := tc.obj
+			if obj == nil {
+				obj = &corev1.Pod{}
+			}
+			got, err := fieldPathKind(obj, tc.path, known)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("fieldPathKind(%q): %v", tc.path, err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("fieldPathKind(%q) = %v, want error containing %q", tc.path, err, tc.wantErr)
+			case got != tc.want:
+				t.Errorf("fieldPathKind(%q) = %s, want %s", tc.path, got, tc.want)
+			}
+		})
+	}
+}
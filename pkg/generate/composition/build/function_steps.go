@@ -0,0 +1,361 @@
+package build
+
+import (
+	"strings"
+
+	xgt "github.com/crossplane-contrib/function-go-templating/input/v1beta1"
+	xpt "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	xenv "github.com/mproffitt/crossbuilder/pkg/generate/composition/input/environmentconfigs/v1beta1"
+	xer "github.com/mproffitt/crossbuilder/pkg/generate/composition/input/extraresources/v1beta1"
+	xkcl "github.com/mproffitt/crossbuilder/pkg/generate/composition/input/kcl/v1alpha1"
+)
+
+const ociScheme = "oci://"
+
+// AutoReadyStep builds a pipeline step for function-auto-ready.
+type AutoReadyStep interface {
+	FunctionStep
+
+	// WithFunctionName overrides the name the function is installed under.
+	WithFunctionName(name string) AutoReadyStep
+}
+
+// AutoReady creates a step for function-auto-ready. The function takes no
+// input.
+func AutoReady() AutoReadyStep {
+	return &autoReadyStep{
+		functionStep: functionStep{function: FunctionAutoReady},
+	}
+}
+
+type autoReadyStep struct {
+	functionStep
+}
+
+// WithFunctionName overrides the name the function is installed under.
+func (s *autoReadyStep) WithFunctionName(name string) AutoReadyStep {
+	s.name = name
+	return s
+}
+
+// GoTemplatingStep builds a pipeline step for function-go-templating.
+type GoTemplatingStep interface {
+	FunctionStep
+
+	// WithFunctionName overrides the name the function is installed under.
+	WithFunctionName(name string) GoTemplatingStep
+
+	// WithInline uses the given template as inline source.
+	WithInline(template string) GoTemplatingStep
+
+	// WithFileSystem reads the templates from the given directory inside
+	// the function container.
+	WithFileSystem(dirPath string) GoTemplatingStep
+
+	// WithDelims sets the template delimiters.
+	WithDelims(left, right string) GoTemplatingStep
+}
+
+// GoTemplating creates a step for function-go-templating.
+func GoTemplating() GoTemplatingStep {
+	input := &xgt.GoTemplate{}
+	return &goTemplatingStep{
+		functionStep: functionStep{function: FunctionGoTemplating, input: input},
+		template:     input,
+	}
+}
+
+type goTemplatingStep struct {
+	functionStep
+	template *xgt.GoTemplate
+}
+
+// WithFunctionName overrides the name the function is installed under.
+func (s *goTemplatingStep) WithFunctionName(name string) GoTemplatingStep {
+	s.name = name
+	return s
+}
+
+// WithInline uses the given template as inline source.
+func (s *goTemplatingStep) WithInline(template string) GoTemplatingStep {
+	s.template.Source = xgt.InlineSource
+	s.template.Inline = &xgt.TemplateSourceInline{Template: template}
+	s.template.FileSystem = nil
+	return s
+}
+
+// WithFileSystem reads the templates from the given directory inside the
+// function container.
+func (s *goTemplatingStep) WithFileSystem(dirPath string) GoTemplatingStep {
+	s.template.Source = xgt.FileSystemSource
+	s.template.FileSystem = &xgt.TemplateSourceFileSystem{DirPath: dirPath}
+	s.template.Inline = nil
+	return s
+}
+
+// WithDelims sets the template delimiters.
+func (s *goTemplatingStep) WithDelims(left, right string) GoTemplatingStep {
+	s.template.Delims = &xgt.Delims{Left: &left, Right: &right}
+	return s
+}
+
+// PatchAndTransformStep builds a pipeline step for
+// function-patch-and-transform.
+type PatchAndTransformStep interface {
+	FunctionStep
+
+	// WithFunctionName overrides the name the function is installed under.
+	WithFunctionName(name string) PatchAndTransformStep
+
+	// WithResources adds the given composed templates.
+	WithResources(resources ...xpt.ComposedTemplate) PatchAndTransformStep
+
+	// WithPatchSets adds the given patch sets.
+	WithPatchSets(patchSets ...xpt.PatchSet) PatchAndTransformStep
+
+	// WithEnvironmentPatches adds the given environment patches.
+	WithEnvironmentPatches(patches ...xpt.EnvironmentPatch) PatchAndTransformStep
+}
+
+// PatchAndTransform creates a step for function-patch-and-transform.
+func PatchAndTransform() PatchAndTransformStep {
+	input := &xpt.Resources{}
+	return &patchAndTransformStep{
+		functionStep: functionStep{function: FunctionPatchAndTransform, input: input},
+		resources:    input,
+	}
+}
+
+type patchAndTransformStep struct {
+	functionStep
+	resources *xpt.Resources
+}
+
+// WithFunctionName overrides the name the function is installed under.
+func (s *patchAndTransformStep) WithFunctionName(name string) PatchAndTransformStep {
+	s.name = name
+	return s
+}
+
+// WithResources adds the given composed templates.
+func (s *patchAndTransformStep) WithResources(resources ...xpt.ComposedTemplate) PatchAndTransformStep {
+	s.resources.Resources = append(s.resources.Resources, resources...)
+	return s
+}
+
+// WithPatchSets adds the given patch sets.
+func (s *patchAndTransformStep) WithPatchSets(patchSets ...xpt.PatchSet) PatchAndTransformStep {
+	s.resources.PatchSets = append(s.resources.PatchSets, patchSets...)
+	return s
+}
+
+// WithEnvironmentPatches adds the given environment patches.
+func (s *patchAndTransformStep) WithEnvironmentPatches(patches ...xpt.EnvironmentPatch) PatchAndTransformStep {
+	if s.resources.Environment == nil {
+		s.resources.Environment = &xpt.Environment{}
+	}
+	s.resources.Environment.Patches = append(s.resources.Environment.Patches, patches...)
+	return s
+}
+
+// KCLStep builds a pipeline step for function-kcl.
+type KCLStep interface {
+	FunctionStep
+
+	// WithFunctionName overrides the name the function is installed under.
+	WithFunctionName(name string) KCLStep
+
+	// WithOCISource runs the KCL module at the given OCI reference. The
+	// oci:// scheme is added if missing.
+	WithOCISource(ref string) KCLStep
+
+	// WithInlineSource runs the given KCL code.
+	WithInlineSource(code string) KCLStep
+
+	// WithDependencies sets the kcl.mod dependencies of an inline source.
+	WithDependencies(dependencies string) KCLStep
+
+	// WithParams sets the top level arguments passed to the KCL program.
+	WithParams(params map[string]runtime.RawExtension) KCLStep
+
+	// WithTarget sets what the function does with the program output.
+	WithTarget(target xkcl.Target) KCLStep
+}
+
+// KCL creates a step for function-kcl.
+func KCL() KCLStep {
+	input := &xkcl.KCLInput{}
+	return &kclStep{
+		functionStep: functionStep{function: FunctionKCL, input: input},
+		kcl:          input,
+	}
+}
+
+type kclStep struct {
+	functionStep
+	kcl *xkcl.KCLInput
+}
+
+// WithFunctionName overrides the name the function is installed under.
+func (s *kclStep) WithFunctionName(name string) KCLStep {
+	s.name = name
+	return s
+}
+
+// WithOCISource runs the KCL module at the given OCI reference.
+func (s *kclStep) WithOCISource(ref string) KCLStep {
+	if !strings.HasPrefix(ref, ociScheme) {
+		ref = ociScheme + ref
+	}
+	s.kcl.Spec.Source = ref
+	return s
+}
+
+// WithInlineSource runs the given KCL code.
+func (s *kclStep) WithInlineSource(code string) KCLStep {
+	s.kcl.Spec.Source = code
+	return s
+}
+
+// WithDependencies sets the kcl.mod dependencies of an inline source.
+func (s *kclStep) WithDependencies(dependencies string) KCLStep {
+	s.kcl.Spec.Dependencies = dependencies
+	return s
+}
+
+// WithParams sets the top level arguments passed to the KCL program.
+func (s *kclStep) WithParams(params map[string]runtime.RawExtension) KCLStep {
+	s.kcl.Spec.Params = params
+	return s
+}
+
+// WithTarget sets what the function does with the program output.
+func (s *kclStep) WithTarget(target xkcl.Target) KCLStep {
+	s.kcl.Spec.Target = target
+	return s
+}
+
+// EnvironmentConfigsStep builds a pipeline step for
+// function-environment-configs.
+type EnvironmentConfigsStep interface {
+	FunctionStep
+
+	// WithFunctionName overrides the name the function is installed under.
+	WithFunctionName(name string) EnvironmentConfigsStep
+
+	// WithReference selects the EnvironmentConfig with the given name.
+	WithReference(name string) EnvironmentConfigsStep
+
+	// WithSelector selects EnvironmentConfigs by labels.
+	WithSelector(selector xapiextv1.EnvironmentSourceSelector) EnvironmentConfigsStep
+
+	// WithDefaultData sets the data merged into the environment before any
+	// selected EnvironmentConfig.
+	WithDefaultData(data map[string]extv1.JSON) EnvironmentConfigsStep
+
+	// WithPolicy sets the resolution policy of the selected
+	// EnvironmentConfigs.
+	WithPolicy(policy xpv1.Policy) EnvironmentConfigsStep
+}
+
+// EnvironmentConfigs creates a step for function-environment-configs.
+func EnvironmentConfigs() EnvironmentConfigsStep {
+	input := &xenv.Input{}
+	return &environmentConfigsStep{
+		functionStep: functionStep{function: FunctionEnvironmentConfigs, input: input},
+		env:          input,
+	}
+}
+
+type environmentConfigsStep struct {
+	functionStep
+	env *xenv.Input
+}
+
+// WithFunctionName overrides the name the function is installed under.
+func (s *environmentConfigsStep) WithFunctionName(name string) EnvironmentConfigsStep {
+	s.name = name
+	return s
+}
+
+// WithReference selects the EnvironmentConfig with the given name.
+func (s *environmentConfigsStep) WithReference(name string) EnvironmentConfigsStep {
+	s.env.Spec.EnvironmentConfigs = append(s.env.Spec.EnvironmentConfigs, xapiextv1.EnvironmentSource{
+		Type: xapiextv1.EnvironmentSourceTypeReference,
+		Ref:  &xapiextv1.EnvironmentSourceReference{Name: name},
+	})
+	return s
+}
+
+// WithSelector selects EnvironmentConfigs by labels.
+func (s *environmentConfigsStep) WithSelector(selector xapiextv1.EnvironmentSourceSelector) EnvironmentConfigsStep {
+	s.env.Spec.EnvironmentConfigs = append(s.env.Spec.EnvironmentConfigs, xapiextv1.EnvironmentSource{
+		Type:     xapiextv1.EnvironmentSourceTypeSelector,
+		Selector: &selector,
+	})
+	return s
+}
+
+// WithDefaultData sets the data merged into the environment before any
+// selected EnvironmentConfig.
+func (s *environmentConfigsStep) WithDefaultData(data map[string]extv1.JSON) EnvironmentConfigsStep {
+	s.env.Spec.DefaultData = data
+	return s
+}
+
+// WithPolicy sets the resolution policy of the selected EnvironmentConfigs.
+func (s *environmentConfigsStep) WithPolicy(policy xpv1.Policy) EnvironmentConfigsStep {
+	s.env.Spec.Policy = &policy
+	return s
+}
+
+// ExtraResourcesStep builds a pipeline step for function-extra-resources.
+type ExtraResourcesStep interface {
+	FunctionStep
+
+	// WithFunctionName overrides the name the function is installed under.
+	WithFunctionName(name string) ExtraResourcesStep
+
+	// WithExtraResources adds the given resource sources.
+	WithExtraResources(sources ...xer.ResourceSource) ExtraResourcesStep
+
+	// WithPolicy sets the resolution policy of the selected resources.
+	WithPolicy(policy xpv1.Policy) ExtraResourcesStep
+}
+
+// ExtraResources creates a step for function-extra-resources.
+func ExtraResources() ExtraResourcesStep {
+	input := &xer.Input{}
+	return &extraResourcesStep{
+		functionStep: functionStep{function: FunctionExtraResources, input: input},
+		extra:        input,
+	}
+}
+
+type extraResourcesStep struct {
+	functionStep
+	extra *xer.Input
+}
+
+// WithFunctionName overrides the name the function is installed under.
+func (s *extraResourcesStep) WithFunctionName(name string) ExtraResourcesStep {
+	s.name = name
+	return s
+}
+
+// WithExtraResources adds the given resource sources.
+func (s *extraResourcesStep) WithExtraResources(sources ...xer.ResourceSource) ExtraResourcesStep {
+	s.extra.Spec.ExtraResources = append(s.extra.Spec.ExtraResources, sources...)
+	return s
+}
+
+// WithPolicy sets the resolution policy of the selected resources.
+func (s *extraResourcesStep) WithPolicy(policy xpv1.Policy) ExtraResourcesStep {
+	s.extra.Spec.Policy = &policy
+	return s
+}
@@ -0,0 +1,143 @@
+package build
+
+import (
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xenv "github.com/mproffitt/crossbuilder/pkg/generate/composition/input/environmentconfigs/v1beta1"
+	xer "github.com/mproffitt/crossbuilder/pkg/generate/composition/input/extraresources/v1beta1"
+	xkcl "github.com/mproffitt/crossbuilder/pkg/generate/composition/input/kcl/v1alpha1"
+)
+
+const (
+	errFmtUnknownFunction      = "function %q is not registered"
+	errFmtFunctionTakesNoInput = "function %q does not accept an input"
+	errFmtBuildFunctionInput   = "cannot build input for function %q"
+)
+
+// Names of the composition functions known to crossbuilder by default.
+const (
+	FunctionAutoReady          = "function-auto-ready"
+	FunctionEnvironmentConfigs = "function-environment-configs"
+	FunctionExtraResources     = "function-extra-resources"
+	FunctionGoTemplating       = "function-go-templating"
+	FunctionKCL                = "function-kcl"
+	FunctionPatchAndTransform  = "function-patch-and-transform"
+)
+
+var (
+	// GoTemplateGroupVersionKind is the GroupVersionKind of the
+	// function-go-templating input.
+	GoTemplateGroupVersionKind = schema.GroupVersionKind{
+		Group:   "gotemplating.fn.crossplane.io",
+		Version: "v1beta1",
+		Kind:    "GoTemplate",
+	}
+
+	// ResourcesGroupVersionKind is the GroupVersionKind of the
+	// function-patch-and-transform input.
+	ResourcesGroupVersionKind = schema.GroupVersionKind{
+		Group:   "pt.fn.crossplane.io",
+		Version: "v1beta1",
+		Kind:    "Resources",
+	}
+)
+
+// FunctionDefinition describes a composition function and the input it
+// accepts.
+type FunctionDefinition struct {
+	// Name is the name the function is installed under in the cluster.
+	Name string
+
+	// InputGroupVersionKind is the GroupVersionKind of the function input.
+	// Leave empty for functions that do not take an input.
+	InputGroupVersionKind schema.GroupVersionKind
+}
+
+var functionDefinitions = map[string]FunctionDefinition{}
+
+func init() {
+	RegisterFunction(FunctionDefinition{
+		Name: FunctionAutoReady,
+	})
+	RegisterFunction(FunctionDefinition{
+		Name:                  FunctionEnvironmentConfigs,
+		InputGroupVersionKind: xenv.InputGroupVersionKind,
+	})
+	RegisterFunction(FunctionDefinition{
+		Name:                  FunctionExtraResources,
+		InputGroupVersionKind: xer.InputGroupVersionKind,
+	})
+	RegisterFunction(FunctionDefinition{
+		Name:                  FunctionGoTemplating,
+		InputGroupVersionKind: GoTemplateGroupVersionKind,
+	})
+	RegisterFunction(FunctionDefinition{
+		Name:                  FunctionKCL,
+		InputGroupVersionKind: xkcl.KCLInputGroupVersionKind,
+	})
+	RegisterFunction(FunctionDefinition{
+		Name:                  FunctionPatchAndTransform,
+		InputGroupVersionKind: ResourcesGroupVersionKind,
+	})
+}
+
+// RegisterFunction makes the given function known to crossbuilder so steps
+// can be created for it with NewFunctionStep. Registering a function with the
+// name of an existing one replaces the previous definition.
+func RegisterFunction(def FunctionDefinition) {
+	functionDefinitions[def.Name] = def
+}
+
+// FunctionStep builds the function reference and input of a pipeline step.
+type FunctionStep interface {
+	// FunctionRef returns the reference to the function executing the step.
+	FunctionRef() xapiextv1.FunctionReference
+
+	// Input returns the input passed to the function or nil if the step has
+	// no input.
+	Input() (Object, error)
+}
+
+// NewFunctionStep creates a FunctionStep for the registered function with
+// the given name. The TypeMeta of the input is set from the function
+// definition. Input may be nil for steps without input.
+func NewFunctionStep(function string, input Object) FunctionStep {
+	return &functionStep{
+		function: function,
+		input:    input,
+	}
+}
+
+type functionStep struct {
+	function string
+	name     string
+	input    Object
+}
+
+// FunctionRef returns the reference to the function executing the step.
+func (f *functionStep) FunctionRef() xapiextv1.FunctionReference {
+	if f.name != "" {
+		return xapiextv1.FunctionReference{Name: f.name}
+	}
+	return xapiextv1.FunctionReference{Name: f.function}
+}
+
+// Input returns the input for the function with the TypeMeta set from the
+// function definition.
+func (f *functionStep) Input() (Object, error) {
+	def, ok := functionDefinitions[f.function]
+	if !ok {
+		return nil, errors.Errorf(errFmtUnknownFunction, f.function)
+	}
+	if f.input == nil {
+		return nil, nil
+	}
+	if def.InputGroupVersionKind.Empty() {
+		return nil, errors.Errorf(errFmtFunctionTakesNoInput, f.function)
+	}
+
+	f.input.SetGroupVersionKind(def.InputGroupVersionKind)
+	return f.input, nil
+}
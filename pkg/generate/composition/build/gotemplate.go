@@ -0,0 +1,332 @@
+package build
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	sprig "github.com/Masterminds/sprig/v3"
+	xgt "github.com/crossplane-contrib/function-go-templating/input/v1beta1"
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	errParseGoTemplate              = "cannot parse go template"
+	errFmtInvalidCompositeReference = "invalid reference to the composite resource at %s"
+
+	goTemplateName = "manifests"
+
+	// goTemplateIgnoreMarker disables the validation of references to the
+	// composite resource on the template line it appears on.
+	goTemplateIgnoreMarker = "crossbuilder:ignore"
+)
+
+// goTemplateFuncs are the custom functions made available to templates by
+// function-go-templating. They are only used to parse templates, so none of
+// them are ever called.
+var goTemplateFuncs = template.FuncMap{
+	"randomChoice":              func(choices ...string) string { return "" },
+	"toYaml":                    func(val any) (string, error) { return "", nil },
+	"fromYaml":                  func(val string) (any, error) { return nil, nil },
+	"getResourceCondition":      func(ct string, res map[string]any) xpv1.Condition { return xpv1.Condition{} },
+	"setResourceNameAnnotation": func(name string) string { return "" },
+	"include":                   func(name string, data any) (string, error) { return "", nil },
+}
+
+// goTemplateLineRe matches the template name and line number text/template
+// prefixes its errors with.
+var goTemplateLineRe = regexp.MustCompile(`template: ` + goTemplateName + `:(\d+)`)
+
+// parseGoTemplate parses the given template the same way function-go-templating
+// does. Line numbers in errors are translated to the files the template was
+// loaded from with LoadTemplate.
+func parseGoTemplate(text string, delims *xgt.Delims) (*template.Template, error) {
+	tpl := template.New(goTemplateName)
+	if delims != nil && delims.Left != nil && delims.Right != nil {
+		tpl = tpl.Delims(*delims.Left, *delims.Right)
+	}
+	tpl.Funcs(goTemplateFuncs).Funcs(sprig.FuncMap())
+
+	tpl, err := tpl.Parse(text)
+	if err != nil {
+		return nil, errors.New(goTemplateLineRe.ReplaceAllStringFunc(err.Error(), func(m string) string {
+			line, _ := strconv.Atoi(goTemplateLineRe.FindStringSubmatch(m)[1])
+			return "template: " + templateSourceOf(text, line)
+		}))
+	}
+	return tpl, nil
+}
+
+// templateSourceOf returns the file and line the given line of a template
+// loaded with LoadTemplate was read from.
+func templateSourceOf(text string, line int) string {
+	file, fileLine := goTemplateName, line
+	for _, s := range templateSources(text) {
+		if s.line > line {
+			break
+		}
+		file, fileLine = s.file, line-s.line+1
+	}
+	return fmt.Sprintf("%s:%d", file, fileLine)
+}
+
+// compositeReference is a field chain into the composite resource found in a
+// template.
+type compositeReference struct {
+	segments fieldpath.Segments
+	pos      parse.Pos
+}
+
+// compositeReferenceWalker collects references to the observed composite
+// resource from the parse tree of a template. References are chains rooted at
+// .observed.composite.resource, $.observed.composite.resource and $xr, or any
+// variable assigned one of these, and index calls on such chains.
+type compositeReferenceWalker struct {
+	vars       map[string]fieldpath.Segments
+	references []compositeReference
+}
+
+// compositeReferences returns the references to the composite resource in the
+// main tree of the given template.
+func compositeReferences(tpl *template.Template) []compositeReference {
+	w := &compositeReferenceWalker{
+		vars: map[string]fieldpath.Segments{
+			"$xr": {},
+		},
+	}
+	if tpl.Tree != nil && tpl.Tree.Root != nil {
+		w.walk(tpl.Tree.Root, true)
+	}
+	return w.references
+}
+
+// walk visits the given node. rootDot is false where dot has been changed by
+// range or with and no longer refers to the template data.
+func (w *compositeReferenceWalker) walk(node parse.Node, rootDot bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			w.walk(child, rootDot)
+		}
+	case *parse.ActionNode:
+		w.walk(n.Pipe, rootDot)
+	case *parse.TemplateNode:
+		w.walk(n.Pipe, rootDot)
+	case *parse.IfNode:
+		w.walkBranch(&n.BranchNode, rootDot, rootDot)
+	case *parse.RangeNode:
+		// Variables declared by range hold the elements, not the ranged
+		// value, so only the commands of the pipeline are visited.
+		w.walkCommands(n.Pipe, rootDot)
+		w.forget(n.Pipe)
+		w.walk(n.List, false)
+		w.walk(n.ElseList, rootDot)
+	case *parse.WithNode:
+		w.walkBranch(&n.BranchNode, rootDot, false)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		w.walkCommands(n, rootDot)
+		w.forget(n)
+		if len(n.Decl) == 1 && len(n.Cmds) == 1 {
+			if segments, ok := w.command(n.Cmds[0], rootDot); ok {
+				w.vars[n.Decl[0].Ident[0]] = segments
+			}
+		}
+	case *parse.CommandNode:
+		if segments, ok := w.index(n, rootDot); ok {
+			w.add(segments, n.Args[1].Position())
+			return
+		}
+		for _, arg := range n.Args {
+			if segments, ok := w.reference(arg, rootDot); ok {
+				w.add(segments, arg.Position())
+				continue
+			}
+			w.walk(arg, rootDot)
+		}
+	}
+}
+
+// forget drops the variables declared or assigned by the given pipeline, so
+// references through a variable no longer holding the composite, such as a
+// reassigned $xr, are not validated against it.
+func (w *compositeReferenceWalker) forget(n *parse.PipeNode) {
+	if n == nil {
+		return
+	}
+	for _, v := range n.Decl {
+		delete(w.vars, v.Ident[0])
+	}
+}
+
+func (w *compositeReferenceWalker) walkCommands(n *parse.PipeNode, rootDot bool) {
+	if n == nil {
+		return
+	}
+	for _, cmd := range n.Cmds {
+		w.walk(cmd, rootDot)
+	}
+}
+
+func (w *compositeReferenceWalker) walkBranch(n *parse.BranchNode, rootDot, listRootDot bool) {
+	w.walk(n.Pipe, rootDot)
+	w.walk(n.List, listRootDot)
+	w.walk(n.ElseList, rootDot)
+}
+
+func (w *compositeReferenceWalker) add(segments fieldpath.Segments, pos parse.Pos) {
+	if len(segments) > 0 {
+		w.references = append(w.references, compositeReference{segments: segments, pos: pos})
+	}
+}
+
+// command returns the reference a command evaluates to if it is a plain
+// reference or an index call on a reference.
+func (w *compositeReferenceWalker) command(cmd *parse.CommandNode, rootDot bool) (fieldpath.Segments, bool) {
+	if segments, ok := w.index(cmd, rootDot); ok {
+		return segments, true
+	}
+	if len(cmd.Args) == 1 {
+		return w.reference(cmd.Args[0], rootDot)
+	}
+	return nil, false
+}
+
+// index returns the reference for calls of the form index <ref> "key" 0 ...
+// The reference is extended by the literal arguments up to the first one that
+// is not a string or integer.
+func (w *compositeReferenceWalker) index(cmd *parse.CommandNode, rootDot bool) (fieldpath.Segments, bool) {
+	if len(cmd.Args) < 2 {
+		return nil, false
+	}
+	if ident, ok := cmd.Args[0].(*parse.IdentifierNode); !ok || ident.Ident != "index" {
+		return nil, false
+	}
+	segments, ok := w.reference(cmd.Args[1], rootDot)
+	if !ok {
+		return nil, false
+	}
+	for _, arg := range cmd.Args[2:] {
+		segment, ok := literalSegment(arg)
+		if !ok {
+			break
+		}
+		segments = append(segments, segment)
+	}
+	return segments, true
+}
+
+func literalSegment(node parse.Node) (fieldpath.Segment, bool) {
+	switch n := node.(type) {
+	case *parse.StringNode:
+		return fieldpath.Field(n.Text), true
+	case *parse.NumberNode:
+		if n.IsUint {
+			return fieldpath.Segment{Type: fieldpath.SegmentIndex, Index: uint(n.Uint64)}, true
+		}
+	}
+	return fieldpath.Segment{}, false
+}
+
+// reference returns the path into the composite resource the given node
+// refers to.
+func (w *compositeReferenceWalker) reference(node parse.Node, rootDot bool) (fieldpath.Segments, bool) {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		if !rootDot {
+			return nil, false
+		}
+		return observedComposite(n.Ident)
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" {
+			return observedComposite(n.Ident[1:])
+		}
+		prefix, ok := w.vars[n.Ident[0]]
+		if !ok {
+			return nil, false
+		}
+		return appendFields(prefix, n.Ident[1:]), true
+	case *parse.PipeNode:
+		if len(n.Decl) != 0 || len(n.Cmds) != 1 {
+			return nil, false
+		}
+		return w.command(n.Cmds[0], rootDot)
+	case *parse.ChainNode:
+		prefix, ok := w.reference(n.Node, rootDot)
+		if !ok {
+			return nil, false
+		}
+		return appendFields(prefix, n.Field), true
+	}
+	return nil, false
+}
+
+// observedComposite returns the path into the composite resource if the given
+// field chain starts with observed.composite.resource.
+func observedComposite(ident []string) (fieldpath.Segments, bool) {
+	root := []string{"observed", "composite", "resource"}
+	if len(ident) < len(root) {
+		return nil, false
+	}
+	for i, r := range root {
+		if ident[i] != r {
+			return nil, false
+		}
+	}
+	return appendFields(nil, ident[len(root):]), true
+}
+
+func appendFields(prefix fieldpath.Segments, fields []string) fieldpath.Segments {
+	segments := make(fieldpath.Segments, len(prefix), len(prefix)+len(fields))
+	copy(segments, prefix)
+	for _, f := range fields {
+		segments = append(segments, fieldpath.Field(f))
+	}
+	return segments
+}
+
+// validateCompositeReferences validates all references to the composite
+// resource in the given template against the composite type. Lines containing
+// the ignore marker are skipped.
+func validateCompositeReferences(text string, tpl *template.Template, composite runtime.Object, knownPaths []fieldpath.Segments) error {
+	lines := strings.Split(text, "\n")
+	for _, ref := range compositeReferences(tpl) {
+		line := strings.Count(text[:ref.pos], "\n") + 1
+		if strings.Contains(lines[line-1], goTemplateIgnoreMarker) {
+			continue
+		}
+		if _, err := validateSegments(composite, ref.segments, knownPaths); err != nil {
+			return errors.Wrapf(errors.Wrap(err, ref.segments.String()), errFmtInvalidCompositeReference, templateSourceOf(text, line))
+		}
+	}
+	return nil
+}
+
+// validateStepTemplate validates the references to the composite resource in
+// the inline go template of the given pipeline step, if it has one.
+func validateStepTemplate(ps xapiextv1.PipelineStep, composite runtime.Object, knownPaths []fieldpath.Segments) error {
+	if ps.Input == nil || !isTypedObject(composite) {
+		return nil
+	}
+	in, ok := ps.Input.Object.(*xgt.GoTemplate)
+	if !ok || in.Source != xgt.InlineSource || in.Inline == nil {
+		return nil
+	}
+	tpl, err := parseGoTemplate(in.Inline.Template, in.Delims)
+	if err != nil {
+		return errors.Wrap(err, errParseGoTemplate)
+	}
+	return validateCompositeReferences(in.Inline.Template, tpl, composite, knownPaths)
+}
@@ -0,0 +1,134 @@
+package build
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestCompositeReferences(t *testing.T) {
+	cases := map[string]struct {
+		template string
+		want     []string
+	}{
+		"Field": {
+			template: `{{ .observed.composite.resource.spec.nodeName }}`,
+			want:     []string{"spec.nodeName"},
+		},
+		"RootVariable": {
+			template: `{{ $.observed.composite.resource.metadata.name }}`,
+			want:     []string{"metadata.name"},
+		},
+		"XRVariable": {
+			template: `{{ $xr := .observed.composite.resource }}{{ $xr.spec.hostname }}`,
+			want:     []string{"spec.hostname"},
+		},
+		"AssignedVariable": {
+			template: `{{ $spec := .observed.composite.resource.spec }}{{ $spec.nodeName }}`,
+			want:     []string{"spec", "spec.nodeName"},
+		},
+		"Index": {
+			template: `{{ index .observed.composite.resource.metadata.labels "app" }}`,
+			want:     []string{"metadata.labels.app"},
+		},
+		"IndexStopsAtNonLiteral": {
+			template: `{{ $xr := .observed.composite.resource }}{{ $k := "app" }}{{ index $xr.spec.containers 0 $k }}`,
+			want:     []string{"spec.containers[0]"},
+		},
+		"Chain": {
+			template: `{{ (.observed.composite.resource.spec).nodeName }}`,
+			want:     []string{"spec.nodeName"},
+		},
+		"FunctionArgument": {
+			template: `{{ toYaml .observed.composite.resource.spec.hostname }}`,
+			want:     []string{"spec.hostname"},
+		},
+		"IfCondition": {
+			template: `{{ if .observed.composite.resource.spec.hostname }}{{ .observed.composite.resource.spec.nodeName }}{{ end }}`,
+			want:     []string{"spec.hostname", "spec.nodeName"},
+		},
+		"RangeChangesDot": {
+			template: `{{ range .observed.composite.resource.spec.containers }}{{ .observed.composite.resource.spec.nope }}{{ end }}`,
+			want:     []string{"spec.containers"},
+		},
+		"RangeVariableHoldsElement": {
+			template: `{{ $xr := .observed.composite.resource }}{{ range $i, $c := $xr.spec.containers }}{{ $c.image }}{{ end }}`,
+			want:     []string{"spec.containers"},
+		},
+		"XRDeclaredAsOtherData": {
+			template: `{{ $xr := .observed.resources.bucket.resource }}{{ $xr.spec.forProvider.region }}`,
+		},
+		"XRReassigned": {
+			template: `{{ $xr := .observed.composite.resource }}{{ $xr.spec.hostname }}{{ $xr = .observed.resources }}{{ $xr.bucket }}`,
+			want:     []string{"spec.hostname"},
+		},
+		"XRReassignedToPipeline": {
+			template: `{{ $xr := .observed.composite.resource }}{{ $xr = $xr.spec | toYaml }}{{ $xr.nope }}`,
+			want:     []string{"spec"},
+		},
+		"RangeVariableShadowsXR": {
+			template: `{{ $xr := .observed.composite.resource }}{{ range $xr = $xr.spec.containers }}{{ $xr.image }}{{ end }}`,
+			want:     []string{"spec.containers"},
+		},
+		"WithChangesDot": {
+			template: `{{ $xr := .observed.composite.resource }}{{ with $xr.spec }}{{ .nodeName }}{{ end }}`,
+			want:     []string{"spec"},
+		},
+		"OtherData": {
+			template: `{{ .observed.resources.foo }}{{ $.desired.composite.resource.spec }}`,
+		},
+		"CompositeRoot": {
+			template: `{{ .observed.composite.resource }}`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tpl, err := parseGoTemplate(tc.template, nil)
+			if err != nil {
+				t.Fatalf("parseGoTemplate: %v", err)
+			}
+			var got []string
+			for _, ref := range compositeReferences(tpl) {
+				got = append(got, ref.segments.String())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("compositeReferences() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateCompositeReferences(t *testing.T) {
+	cases := map[string]struct {
+		template string
+		wantErr  string
+	}{
+		"Valid": {
+			template: "{{ $xr := .observed.composite.resource }}{{ $xr.spec.nodeName }}\n{{ index $xr.metadata.labels \"app\" }}",
+		},
+		"UnknownField": {
+			template: "{{ $xr := .observed.composite.resource }}{{ $xr.spec.nodeName }}\n{{ $xr.spec.nope }}",
+			wantErr:  "invalid reference to the composite resource at manifests:2",
+		},
+		"Ignored": {
+			template: "{{ $xr := .observed.composite.resource }}{{ $xr.spec.nope }} {{/* crossbuilder:ignore */}}",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tpl, err := parseGoTemplate(tc.template, nil)
+			if err != nil {
+				t.Fatalf("parseGoTemplate: %v", err)
+			}
+			err = validateCompositeReferences(tc.template, tpl, &corev1.Pod{}, nil)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("validateCompositeReferences(): %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("validateCompositeReferences() = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
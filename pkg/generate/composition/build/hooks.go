@@ -0,0 +1,95 @@
+package build
+
+import (
+	"fmt"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+)
+
+const (
+	errFmtRunHook = "failed to run %s"
+
+	fmtHookAtIndex = "hook at index %d"
+	fmtNamedHook   = "hook %q"
+	fmtStepHook    = "step %s"
+)
+
+// Hook customizes every composition built by a Runner, for example to add
+// the same labels or pipeline steps to all of them. Either function may be
+// nil.
+type Hook struct {
+	// Name identifies the hook in errors.
+	Name string
+
+	// Skeleton is run on the skeleton of every composition after its
+	// builder and before the composition is built, for example to add
+	// steps or register paths.
+	Skeleton func(composite ObjectKindReference, c CompositionSkeleton) error
+
+	// Composition is run on every composition once it is built and before
+	// it is written, for example to add labels or strip fields.
+	Composition func(c *xapiextv1.Composition) error
+}
+
+// describe returns the name of the hook at the given index for use in
+// errors.
+func (h Hook) describe(index int) string {
+	if h.Name != "" {
+		return fmt.Sprintf(fmtNamedHook, h.Name)
+	}
+	return fmt.Sprintf(fmtHookAtIndex, index)
+}
+
+// LabelsHook returns a hook that adds the given labels to every composition.
+// They replace labels with the same key set by builders.
+func LabelsHook(labels map[string]string) Hook {
+	return Hook{
+		Name: "labels",
+		Composition: func(c *xapiextv1.Composition) error {
+			l := c.GetLabels()
+			if l == nil {
+				l = make(map[string]string, len(labels))
+			}
+			for k, v := range labels {
+				l[k] = v
+			}
+			c.SetLabels(l)
+			return nil
+		},
+	}
+}
+
+// AnnotationsHook returns a hook that adds the given annotations to every
+// composition. They replace annotations with the same key set by builders.
+func AnnotationsHook(annotations map[string]string) Hook {
+	return Hook{
+		Name: "annotations",
+		Composition: func(c *xapiextv1.Composition) error {
+			a := c.GetAnnotations()
+			if a == nil {
+				a = make(map[string]string, len(annotations))
+			}
+			for k, v := range annotations {
+				a[k] = v
+			}
+			c.SetAnnotations(a)
+			return nil
+		},
+	}
+}
+
+// StepHook returns a hook that appends a step with the given name to the
+// pipeline of every composition and configures it with the given function,
+// for example to run a function after the steps added by builders. Steps
+// pinned with AlwaysLast still run after it, unless configure pins the step
+// as well. Compositions in Resources mode have no pipeline and are left
+// unchanged, including those converted to Pipeline mode.
+func StepHook(name string, configure func(s PipelineStepSkeleton)) Hook {
+	return Hook{
+		Name: fmt.Sprintf(fmtStepHook, name),
+		Skeleton: func(_ ObjectKindReference, c CompositionSkeleton) error {
+			configure(c.NewPipelineStep(name))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,128 @@
+package build
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+
+	"github.com/mproffitt/crossbuilder/examples/apis/v1alpha1"
+)
+
+func TestHooks(t *testing.T) {
+	if err := AddToScheme(v1alpha1.AddToScheme); err != nil {
+		t.Fatal(err)
+	}
+	withLabels := func(c CompositionSkeleton) {
+		buildNamed("a")(c)
+		c.WithLabels(map[string]string{"team": "builder", "tier": "1"})
+	}
+	withLastStep := func(c CompositionSkeleton) {
+		buildNamed("a")(c)
+		c.NewPipelineStep("last").WithFunction(AutoReady().WithFunctionName("last")).AlwaysLast()
+	}
+	autoReady := StepHook("auto-ready", func(s PipelineStepSkeleton) {
+		s.WithFunction(AutoReady())
+	})
+
+	cases := map[string]struct {
+		build           func(c CompositionSkeleton)
+		hooks           []Hook
+		wantLabels      map[string]string
+		wantAnnotations map[string]string
+		wantSteps       []string
+		wantErr         string
+	}{
+		"None": {
+			build:     buildNamed("a"),
+			wantSteps: []string{"patch-and-transform"},
+		},
+		"Labels": {
+			build:      withLabels,
+			hooks:      []Hook{LabelsHook(map[string]string{"team": "platform"})},
+			wantLabels: map[string]string{"team": "platform", "tier": "1"},
+			wantSteps:  []string{"patch-and-transform"},
+		},
+		"Annotations": {
+			build:           buildNamed("a"),
+			hooks:           []Hook{AnnotationsHook(map[string]string{"sha": "abc"})},
+			wantAnnotations: map[string]string{"sha": "abc"},
+			wantSteps:       []string{"patch-and-transform"},
+		},
+		"InOrder": {
+			build: buildNamed("a"),
+			hooks: []Hook{
+				LabelsHook(map[string]string{"team": "first"}),
+				LabelsHook(map[string]string{"team": "second"}),
+			},
+			wantLabels: map[string]string{"team": "second"},
+			wantSteps:  []string{"patch-and-transform"},
+		},
+		"Step": {
+			build:     buildNamed("a"),
+			hooks:     []Hook{autoReady},
+			wantSteps: []string{"patch-and-transform", "auto-ready"},
+		},
+		"StepBeforePinnedStep": {
+			build:     withLastStep,
+			hooks:     []Hook{autoReady},
+			wantSteps: []string{"patch-and-transform", "auto-ready", "last"},
+		},
+		"DuplicateStep": {
+			build:   buildNamed("a"),
+			hooks:   []Hook{StepHook("patch-and-transform", func(s PipelineStepSkeleton) { s.WithFunction(AutoReady()) })},
+			wantErr: `step name "patch-and-transform" is already used by step at index 0`,
+		},
+		"NamedSkeletonHookError": {
+			build: buildNamed("a"),
+			hooks: []Hook{{
+				Name:     "failing",
+				Skeleton: func(_ ObjectKindReference, _ CompositionSkeleton) error { return errors.New("boom") },
+			}},
+			wantErr: `failed to run hook "failing": boom`,
+		},
+		"CompositionHookError": {
+			build: buildNamed("a"),
+			hooks: []Hook{
+				autoReady,
+				{Composition: func(_ *xapiextv1.Composition) error { return errors.New("boom") }},
+			},
+			wantErr: "failed to run hook at index 1: boom",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := &recordingWriter{}
+			err := NewRunner(RunnerConfig{
+				Builder: []CompositionBuilder{&testBuilder{composite: ObjectKindReference{Object: &v1alpha1.XExample{}}, build: tc.build}},
+				Writer:  w,
+				Hooks:   tc.hooks,
+			}).Build()
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("Build(): %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Fatalf("Build() = %v, want error containing %q", err, tc.wantErr)
+			case tc.wantErr != "":
+				return
+			}
+
+			c := w.compositions[0]
+			if got := c.GetLabels(); !reflect.DeepEqual(got, tc.wantLabels) {
+				t.Errorf("labels = %v, want %v", got, tc.wantLabels)
+			}
+			if got := c.GetAnnotations(); !reflect.DeepEqual(got, tc.wantAnnotations) {
+				t.Errorf("annotations = %v, want %v", got, tc.wantAnnotations)
+			}
+			var steps []string
+			for _, s := range c.Spec.Pipeline {
+				steps = append(steps, s.Step)
+			}
+			if !reflect.DeepEqual(steps, tc.wantSteps) {
+				t.Errorf("steps = %q, want %q", steps, tc.wantSteps)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	errFmtReadManifest   = "cannot read %s"
+	errFmtDecodeManifest = "cannot decode %s"
+)
+
+// readManifests returns the JSON of all objects of the given kind found in
+// the YAML files in the given directory and its subdirectories. A directory
+// that does not exist contains no objects.
+func readManifests(dir string, gvk schema.GroupVersionKind) ([][]byte, error) {
+	var manifests [][]byte
+	err := walkManifests(dir, func(_ string, raw json.RawMessage, objGVK schema.GroupVersionKind) error {
+		if objGVK == gvk {
+			manifests = append(manifests, raw)
+		}
+		return nil
+	})
+	return manifests, err
+}
+
+// walkManifests calls fn with the JSON and kind of every object found in the
+// YAML files in the given directory and its subdirectories. Documents that
+// are not objects, such as scalars or lists, are skipped, files that are not
+// valid YAML are an error and a directory that does not exist contains no
+// objects.
+func walkManifests(dir string, fn func(path string, raw json.RawMessage, gvk schema.GroupVersionKind) error) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || (filepath.Ext(path) != ".yaml" && filepath.Ext(path) != ".yml") {
+			return nil
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, errFmtReadManifest, path)
+		}
+		decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(b), 4096)
+		for {
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return errors.Wrapf(err, errFmtDecodeManifest, path)
+			}
+			var tm metav1.TypeMeta
+			if err := json.Unmarshal(raw, &tm); err != nil {
+				continue // not an object
+			}
+			if err := fn(path, raw, tm.GroupVersionKind()); err != nil {
+				return err
+			}
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+)
+
+func TestLoadCompositeResourceDefinitions(t *testing.T) {
+	cases := map[string]struct {
+		files   map[string]string
+		want    []string
+		wantErr string
+	}{
+		"NoDirectory": {},
+		"MultipleDocuments": {
+			files: map[string]string{
+				"xrd.yaml": "---\n" + xrd("a") + "---\n" + xrd("b"),
+			},
+			want: []string{"a", "b"},
+		},
+		"OtherObjectsIgnored": {
+			files: map[string]string{
+				"a.yaml":  xrd("a"),
+				"cm.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n",
+			},
+			want: []string{"a"},
+		},
+		"NonObjectDocumentsSkipped": {
+			files: map[string]string{
+				"a.yaml":      "just a string\n---\n- a\n- list\n---\n" + xrd("a"),
+				"empty.yaml":  "",
+				"b.yml":       "---\n---\n" + xrd("b"),
+				"values.yaml": "42\n",
+			},
+			want: []string{"a", "b"},
+		},
+		"InvalidYAML": {
+			files: map[string]string{
+				"a.yaml":        xrd("a"),
+				"template.yaml": "{{ if .Values.enabled }}\nkey: [unclosed\n",
+			},
+			wantErr: filepath.FromSlash("/apis/template.yaml: "),
+		},
+		"OtherExtensionsIgnored": {
+			files: map[string]string{
+				"a.yaml": xrd("a"),
+				"b.json": `{"apiVersion": "apiextensions.crossplane.io/v1", "kind": "CompositeResourceDefinition"}`,
+			},
+			want: []string{"a"},
+		},
+		"DuplicateName": {
+			files: map[string]string{
+				"a.yaml":     xrd("a"),
+				"sub/a.yaml": xrd("a"),
+			},
+			wantErr: `composite resource definition "a" is defined in both`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "apis")
+			for f, content := range tc.files {
+				p := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			xrds, err := LoadCompositeResourceDefinitions(dir)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("LoadCompositeResourceDefinitions(): %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Fatalf("LoadCompositeResourceDefinitions() = %v, want error containing %q", err, tc.wantErr)
+			}
+			var got []string
+			for _, x := range xrds {
+				got = append(got, x.GetName())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("LoadCompositeResourceDefinitions() = %q, want %q", got, tc.want)
+			}
+			for _, x := range xrds {
+				if x.GroupVersionKind() != xapiextv1.CompositeResourceDefinitionGroupVersionKind {
+					t.Errorf("XRD %q has kind %s", x.GetName(), x.GroupVersionKind())
+				}
+			}
+		})
+	}
+}
+
+func xrd(name string) string {
+	return "apiVersion: apiextensions.crossplane.io/v1\nkind: CompositeResourceDefinition\nmetadata:\n  name: " + name + "\n"
+}
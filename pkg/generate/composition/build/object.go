@@ -3,6 +3,7 @@ package build
 import (
 	"fmt"
 
+	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
@@ -18,12 +19,28 @@ type Object interface {
 // runtime.Object.
 type ObjectKindReference struct {
 	// GroupVersionKind is the GroupVersionKind for the composite type.
+	//
+	// May be left empty if the type of Object has been registered with
+	// AddToScheme, in which case it is inferred.
 	GroupVersionKind schema.GroupVersionKind
 
 	// Object is an instance of the composite type.
 	Object Object
 }
 
+// isTypedObject returns true if the given object is backed by a Go type that
+// field paths can be validated against.
+func isTypedObject(obj runtime.Object) bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.(type) {
+	case runtime.Unstructured, *runtime.Unknown:
+		return false
+	}
+	return true
+}
+
 func makeLabelPaths(keys []string) []string {
 	paths := make([]string, len(keys))
 	for i, k := range keys {
@@ -39,3 +56,20 @@ func makeAnnotationPaths(keys []string) []string {
 	}
 	return paths
 }
+
+// resolve fills in the GroupVersionKind of this reference from the object or
+// the scheme if it has not been given and sets it on the object.
+func (o *ObjectKindReference) resolve() error {
+	if o.Object == nil {
+		return errors.New(errNilObject)
+	}
+	if o.GroupVersionKind.Empty() {
+		gvk, err := GroupVersionKindFor(o.Object)
+		if err != nil {
+			return err
+		}
+		o.GroupVersionKind = gvk
+	}
+	o.Object.SetGroupVersionKind(o.GroupVersionKind)
+	return nil
+}
@@ -1,11 +1,13 @@
 package build
 
 import (
+	"encoding/json"
+
+	xpt "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
 	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
 	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
 	"github.com/mproffitt/crossbuilder/pkg/generate/utils"
 	"github.com/pkg/errors"
-	"k8s.io/apimachinery/pkg/runtime"
 )
 
 type patchSkeleton struct {
@@ -13,31 +15,149 @@ type patchSkeleton struct {
 	unsafe bool
 }
 
-func validatePatch(patch *xapiextv1.Patch, from, to runtime.Object, fromKnownPaths, toKnownPaths []fieldpath.Segments) error {
-	if err := ValidateFieldPath(from, utils.StringValue(patch.FromFieldPath), fromKnownPaths); err != nil {
-		return errors.Wrap(err, errPatchFromFieldPath)
-	}
-	if err := ValidateFieldPath(to, utils.StringValue(patch.ToFieldPath), toKnownPaths); err != nil {
-		return errors.Wrap(err, errPatchToFieldPath)
-	}
-	return nil
+type composedPatchSkeleton struct {
+	patch  xpt.ComposedPatch
+	unsafe bool
+}
+
+func validatePatch(patch *xapiextv1.Patch, from, to interface{}, fromKnownPaths, toKnownPaths []fieldpath.Segments) error {
+	return validatePatchPaths(utils.StringValue(patch.FromFieldPath), utils.StringValue(patch.ToFieldPath), from, to, fromKnownPaths, toKnownPaths, patch.Transforms)
 }
 
-func validatePatchCombine(patch *xapiextv1.Patch, from, to runtime.Object, fromKnownPaths, toKnownPaths []fieldpath.Segments) error {
+func validatePatchCombine(patch *xapiextv1.Patch, from, to interface{}, fromKnownPaths, toKnownPaths []fieldpath.Segments) error {
 	if patch.Combine == nil {
 		return errors.Errorf(errPatchRequireField, "combine")
 	}
 	if patch.Combine.Variables == nil {
 		return errors.Errorf(errPatchRequireField, "combine.variables")
 	}
-	if len(patch.Combine.Variables) == 0 {
-		return errors.New(errPatchCombineEmptyVariables)
+	variables := make([]string, len(patch.Combine.Variables))
+	for i, v := range patch.Combine.Variables {
+		variables[i] = v.FromFieldPath
+	}
+	return validateCombinePaths(variables, utils.StringValue(patch.ToFieldPath), from, to, fromKnownPaths, toKnownPaths, patch.Transforms)
+}
+
+// validateComposedPatch validates a patch-and-transform patch between the
+// composite or the environment and the base of a composed resource. Patches
+// referring to patch sets are not validated.
+func validateComposedPatch(patch *xpt.ComposedPatch, composite, environment, base interface{}, compositeKnownPaths, environmentKnownPaths, baseKnownPaths []fieldpath.Segments) error {
+	p, err := toPatch(patch)
+	if err != nil {
+		return err
+	}
+	switch patch.GetType() {
+	case xpt.PatchTypeFromCompositeFieldPath:
+		return validatePatch(&p, composite, base, compositeKnownPaths, baseKnownPaths)
+	case xpt.PatchTypeToCompositeFieldPath:
+		return validatePatch(&p, base, composite, baseKnownPaths, compositeKnownPaths)
+	case xpt.PatchTypeCombineFromComposite:
+		return validatePatchCombine(&p, composite, base, compositeKnownPaths, baseKnownPaths)
+	case xpt.PatchTypeCombineToComposite:
+		return validatePatchCombine(&p, base, composite, baseKnownPaths, compositeKnownPaths)
+	case xpt.PatchTypeFromEnvironmentFieldPath:
+		return validatePatch(&p, environment, base, environmentKnownPaths, baseKnownPaths)
+	case xpt.PatchTypeToEnvironmentFieldPath:
+		return validatePatch(&p, base, environment, baseKnownPaths, environmentKnownPaths)
+	case xpt.PatchTypeCombineFromEnvironment:
+		return validatePatchCombine(&p, environment, base, environmentKnownPaths, baseKnownPaths)
+	case xpt.PatchTypeCombineToEnvironment:
+		return validatePatchCombine(&p, base, environment, baseKnownPaths, environmentKnownPaths)
+	case xpt.PatchTypePatchSet:
+		return nil
+	}
+	return errors.Errorf(errUnknownPatchType, patch.GetType())
+}
+
+// toPatch converts a patch-and-transform patch into its Crossplane equivalent.
+// The toFieldPath defaults to the fromFieldPath as it does in
+// function-patch-and-transform.
+func toPatch(patch *xpt.ComposedPatch) (xapiextv1.Patch, error) {
+	// Policies differ between both and are not needed for validation.
+	in := patch.DeepCopy()
+	in.Policy = nil
+
+	var p xapiextv1.Patch
+	b, err := json.Marshal(in)
+	if err == nil {
+		err = json.Unmarshal(b, &p)
+	}
+	if err != nil {
+		return xapiextv1.Patch{}, errors.Wrap(err, errConvertPatch)
+	}
+	toFieldPath := patch.GetToFieldPath()
+	p.ToFieldPath = &toFieldPath
+	return p, nil
+}
+
+// validateEnvironmentPatch validates a patch between the composite and the
+// environment.
+func validateEnvironmentPatch(patch *xapiextv1.EnvironmentPatch, composite, environment interface{}, compositeKnownPaths, environmentKnownPaths []fieldpath.Segments) error {
+	switch patch.Type {
+	case "", xapiextv1.PatchTypeFromCompositeFieldPath:
+		return validatePatchPaths(utils.StringValue(patch.FromFieldPath), utils.StringValue(patch.ToFieldPath), composite, environment, compositeKnownPaths, environmentKnownPaths, patch.Transforms)
+	case xapiextv1.PatchTypeToCompositeFieldPath:
+		return validatePatchPaths(utils.StringValue(patch.FromFieldPath), utils.StringValue(patch.ToFieldPath), environment, composite, environmentKnownPaths, compositeKnownPaths, patch.Transforms)
+	case xapiextv1.PatchTypeCombineFromComposite:
+		return validateEnvironmentPatchCombine(patch, composite, environment, compositeKnownPaths, environmentKnownPaths)
+	case xapiextv1.PatchTypeCombineToComposite:
+		return validateEnvironmentPatchCombine(patch, environment, composite, environmentKnownPaths, compositeKnownPaths)
 	}
+	return errors.Errorf(errUnknownPatchType, patch.Type)
+}
 
+func validateEnvironmentPatchCombine(patch *xapiextv1.EnvironmentPatch, from, to interface{}, fromKnownPaths, toKnownPaths []fieldpath.Segments) error {
+	if patch.Combine == nil {
+		return errors.Errorf(errPatchRequireField, "combine")
+	}
+	variables := make([]string, len(patch.Combine.Variables))
 	for i, v := range patch.Combine.Variables {
-		if err := ValidateFieldPath(from, v.FromFieldPath, fromKnownPaths); err != nil {
-			return errors.Wrapf(err, errFmtPatchCombineVariableFromFieldPath, i)
+		variables[i] = v.FromFieldPath
+	}
+	return validateCombinePaths(variables, utils.StringValue(patch.ToFieldPath), from, to, fromKnownPaths, toKnownPaths, patch.Transforms)
+}
+
+// validatePatchPaths validates the paths of a patch from one object to
+// another and that the value read, after applying the transforms, can be
+// written to the destination field. Paths of nil objects are not validated,
+// which is the case for the environment if no type has been registered for it.
+func validatePatchPaths(fromPath, toPath string, from, to interface{}, fromKnownPaths, toKnownPaths []fieldpath.Segments, transforms []xapiextv1.Transform) error {
+	fromKind, toKind := valueKindAny, valueKindAny
+	if from != nil {
+		var err error
+		if fromKind, err = fieldPathKind(from, fromPath, fromKnownPaths); err != nil {
+			return errors.Wrap(err, errPatchFromFieldPath)
+		}
+	}
+	if to != nil {
+		var err error
+		if toKind, err = fieldPathKind(to, toPath, toKnownPaths); err != nil {
+			return errors.Wrap(err, errPatchToFieldPath)
+		}
+	}
+	return errors.Wrap(validatePatchTypes(fromKind, transforms, toKind), errPatchTypes)
+}
+
+// validateCombinePaths validates the paths of a combine patch from one object
+// to another. Combined values are always strings.
+func validateCombinePaths(variables []string, toPath string, from, to interface{}, fromKnownPaths, toKnownPaths []fieldpath.Segments, transforms []xapiextv1.Transform) error {
+	if len(variables) == 0 {
+		return errors.New(errPatchCombineEmptyVariables)
+	}
+
+	if from != nil {
+		for i, v := range variables {
+			if err := ValidateFieldPath(from, v, fromKnownPaths); err != nil {
+				return errors.Wrapf(err, errFmtPatchCombineVariableFromFieldPath, i)
+			}
+		}
+	}
+	toKind := valueKindAny
+	if to != nil {
+		var err error
+		if toKind, err = fieldPathKind(to, toPath, toKnownPaths); err != nil {
+			return errors.Wrap(err, errPatchToFieldPath)
 		}
 	}
-	return errors.Wrap(ValidateFieldPath(to, utils.StringValue(patch.ToFieldPath), toKnownPaths), errPatchToFieldPath)
+	return errors.Wrap(validatePatchTypes(valueKindString, transforms, toKind), errPatchTypes)
 }
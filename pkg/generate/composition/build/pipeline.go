@@ -1,8 +1,22 @@
 package build
 
 import (
+	"sort"
+
 	xpt "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
 	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// stepPosition pins a pipeline step to the start or end of the pipeline.
+type stepPosition int
+
+const (
+	stepPositionAny stepPosition = iota
+	stepPositionFirst
+	stepPositionLast
 )
 
 type pipelineStepSkeleton struct {
@@ -10,7 +24,10 @@ type pipelineStepSkeleton struct {
 	step                string
 	functionRef         *xapiextv1.FunctionReference
 	input               *ObjectKindReference
-	patches             map[string][]xpt.ComposedPatch
+	function            FunctionStep
+	credentials         []xapiextv1.FunctionCredentials
+	position            stepPosition
+	patches             map[string][]composedPatchSkeleton
 }
 
 // WithFunctionRef sets the function reference for this pipeline step.
@@ -25,6 +42,44 @@ func (p *pipelineStepSkeleton) WithInput(input ObjectKindReference) PipelineStep
 	return p
 }
 
+// WithFunction sets the function reference and input for this pipeline step
+// from the given FunctionStep.
+func (p *pipelineStepSkeleton) WithFunction(fn FunctionStep) PipelineStepSkeleton {
+	p.function = fn
+	return p
+}
+
+// WithCredentials adds the following credentials to this pipeline step.
+func (p *pipelineStepSkeleton) WithCredentials(credentials ...xapiextv1.FunctionCredentials) PipelineStepSkeleton {
+	p.credentials = append(p.credentials, credentials...)
+	return p
+}
+
+// WithSecretCredentials adds credentials with the given name to this pipeline
+// step which are read from the referenced secret.
+func (p *pipelineStepSkeleton) WithSecretCredentials(name, namespace, secretName string) PipelineStepSkeleton {
+	return p.WithCredentials(xapiextv1.FunctionCredentials{
+		Name:   name,
+		Source: xapiextv1.FunctionCredentialsSourceSecret,
+		SecretRef: &xpv1.SecretReference{
+			Name:      secretName,
+			Namespace: namespace,
+		},
+	})
+}
+
+// AlwaysFirst pins this step to the start of the pipeline.
+func (p *pipelineStepSkeleton) AlwaysFirst() PipelineStepSkeleton {
+	p.position = stepPositionFirst
+	return p
+}
+
+// AlwaysLast pins this step to the end of the pipeline.
+func (p *pipelineStepSkeleton) AlwaysLast() PipelineStepSkeleton {
+	p.position = stepPositionLast
+	return p
+}
+
 // WithStep sets the name for this pipeline step.
 func (p *pipelineStepSkeleton) WithStep(step string) PipelineStepSkeleton {
 	p.step = step
@@ -41,14 +96,163 @@ func (p *pipelineStepSkeleton) WithPatches(name string, patches ...xpt.ComposedP
 
 // WithPatch adds the following patch to this pipeline step.
 func (p *pipelineStepSkeleton) WithPatch(name string, patch xpt.ComposedPatch) PipelineStepSkeleton {
+	p.addPatch(name, patch, false)
+	return p
+}
+
+// WithUnsafePatches is similar to WithPatches but the field paths of the
+// patches will not be validated.
+func (p *pipelineStepSkeleton) WithUnsafePatches(name string, patches ...xpt.ComposedPatch) PipelineStepSkeleton {
+	for _, patch := range patches {
+		p.addPatch(name, patch, true)
+	}
+	return p
+}
+
+func (p *pipelineStepSkeleton) addPatch(name string, patch xpt.ComposedPatch, unsafe bool) {
 	if p.patches == nil {
-		p.patches = make(map[string][]xpt.ComposedPatch)
+		p.patches = make(map[string][]composedPatchSkeleton)
 	}
+	p.patches[name] = append(p.patches[name], composedPatchSkeleton{
+		patch:  patch,
+		unsafe: unsafe,
+	})
+}
 
-	if _, ok := p.patches[name]; !ok {
-		p.patches[name] = make([]xpt.ComposedPatch, 0)
+// resolveFunction returns the function reference and input of this step,
+// built from the FunctionStep if one is set.
+func (p *pipelineStepSkeleton) resolveFunction() (*xapiextv1.FunctionReference, *ObjectKindReference, error) {
+	if p.function == nil {
+		if p.input != nil {
+			if err := p.input.resolve(); err != nil {
+				return nil, nil, errors.Wrap(err, errResolveInputType)
+			}
+		}
+		return p.functionRef, p.input, nil
 	}
 
-	p.patches[name] = append(p.patches[name], patch)
-	return p
+	ref := p.function.FunctionRef()
+	input, err := p.function.Input()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, errFmtBuildFunctionInput, ref.Name)
+	}
+	if input == nil {
+		return &ref, nil, nil
+	}
+	return &ref, &ObjectKindReference{
+		GroupVersionKind: input.GetObjectKind().GroupVersionKind(),
+		Object:           input,
+	}, nil
+}
+
+// prepareInput returns the given input ready to be written to the pipeline.
+//
+// For patch-and-transform inputs the GroupVersionKind of every resource base
+// is resolved and all patches added through WithPatch(es) are merged into the
+// matching composed templates. The original input is left untouched so the
+// skeleton can be converted more than once.
+func (p *pipelineStepSkeleton) prepareInput(input *ObjectKindReference) (runtime.Object, error) {
+	if input == nil || input.Object == nil {
+		if len(p.patches) > 0 {
+			return nil, errors.Errorf(errFmtPatchResourceNotFound, p.patchedResources()[0])
+		}
+		return nil, nil
+	}
+
+	resources, ok := input.Object.(*xpt.Resources)
+	if !ok {
+		if len(p.patches) > 0 {
+			return nil, errors.Wrap(errors.Errorf(errFmtUnexpectedPatchInput, &xpt.Resources{}, input.Object), errFmtInvalidPatchAndTransform)
+		}
+		return input.Object, nil
+	}
+	resources = resources.DeepCopy()
+
+	for i, r := range resources.Resources {
+		if r.Base == nil || r.Base.Object == nil {
+			continue
+		}
+		gvk, err := GroupVersionKindFor(r.Base.Object)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtResolveResourceBaseType, r.Name)
+		}
+		resources.Resources[i].Base.Object.GetObjectKind().SetGroupVersionKind(gvk)
+	}
+
+	for _, name := range p.patchedResources() {
+		found := false
+		for i := range resources.Resources {
+			if resources.Resources[i].Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf(errFmtPatchResourceNotFound, name)
+		}
+	}
+
+	compositePaths, err := parseFieldPaths(p.compositionSkeleton.registeredPaths)
+	if err != nil {
+		return nil, errors.Wrap(err, errParseRegisteredCompositePaths)
+	}
+	environmentPaths, err := parseFieldPaths(p.compositionSkeleton.registeredEnvironmentPaths)
+	if err != nil {
+		return nil, errors.Wrap(err, errParseRegisteredEnvironmentPaths)
+	}
+	basePaths, err := parseFieldPaths(append(makeAnnotationPaths(KnownResourceAnnotations), makeLabelPaths(KnownResourceLabels)...))
+	if err != nil {
+		return nil, errors.Wrap(err, errParseRegisteredComposedPaths)
+	}
+
+	composite := p.compositionSkeleton.composite.Object
+	for i := range resources.Resources {
+		r := &resources.Resources[i]
+		baseObject := unstructuredBase(r.Base)
+		base := validationTarget(p.compositionSkeleton.crds, baseObject)
+		if s, ok := base.(*openAPISchema); ok {
+			if err := s.validateObject(baseObject); err != nil {
+				return nil, errors.Wrapf(err, errFmtInvalidResourceBase, r.Name)
+			}
+		}
+		validate := isTypedObject(composite) && canValidate(base)
+
+		patches := make([]composedPatchSkeleton, 0, len(r.Patches)+len(p.patches[r.Name]))
+		for _, patch := range r.Patches {
+			patches = append(patches, composedPatchSkeleton{patch: patch})
+		}
+		patches = append(patches, p.patches[r.Name]...)
+
+		r.Patches = make([]xpt.ComposedPatch, len(patches))
+		for j, patch := range patches {
+			if validate && !patch.unsafe {
+				if err := validateComposedPatch(&patch.patch, composite, p.compositionSkeleton.environmentType, base, compositePaths, environmentPaths, basePaths); err != nil {
+					return nil, errors.Wrapf(errors.Wrapf(err, errFmtInvalidPatch, j), errFmtInvalidResourcePatch, r.Name)
+				}
+			}
+			r.Patches[j] = patch.patch
+		}
+		if len(r.Patches) == 0 {
+			r.Patches = nil
+		}
+
+		if err := validateComposedConnectionDetails(r.ConnectionDetails, base, basePaths); err != nil {
+			return nil, errors.Wrapf(err, errFmtInvalidResourceConnectionDetail, r.Name)
+		}
+		if err := validateComposedReadinessChecks(r.ReadinessChecks, base, basePaths); err != nil {
+			return nil, errors.Wrapf(err, errFmtInvalidResourceReadinessCheck, r.Name)
+		}
+	}
+	return resources, nil
+}
+
+// patchedResources returns the sorted names of all resources patches have
+// been added for.
+func (p *pipelineStepSkeleton) patchedResources() []string {
+	names := make([]string, 0, len(p.patches))
+	for name := range p.patches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
@@ -0,0 +1,132 @@
+package build
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	errFmtReadGeneratedManifest  = "cannot read manifest %s"
+	errFmtWriteGeneratedManifest = "cannot write manifest %s"
+	errFmtPruneFile              = "cannot delete %s"
+
+	// ManifestFile is the name of the file a DirectoryWriter records the
+	// files it generated in, relative to its directory.
+	ManifestFile = ".crossbuilder-manifest.json"
+)
+
+// generatedManifest lists the files generated into a directory.
+type generatedManifest struct {
+	// Files are the paths of the generated files, relative to the
+	// directory and slash separated.
+	Files []string `json:"files"`
+}
+
+func (w *directoryWriter) Prune(dryRun bool) ([]string, error) {
+	manifestPath := filepath.Join(w.dir, ManifestFile)
+	previous, err := w.generatedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	written := make([]string, 0, len(w.written))
+	for path := range w.written {
+		written = append(written, path)
+	}
+	current, err := w.manifestContent(written)
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []string
+	for _, path := range previous {
+		if !w.written[path] {
+			stale = append(stale, path)
+		}
+	}
+	if dryRun {
+		return stale, nil
+	}
+
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil {
+			return nil, errors.Wrapf(err, errFmtPruneFile, path)
+		}
+		w.removeEmptyDirs(filepath.Dir(path))
+	}
+
+	if err := os.MkdirAll(w.dir, w.dirMode); err != nil {
+		return nil, errors.Wrapf(err, errFmtWriteGeneratedManifest, manifestPath)
+	}
+	if err := os.WriteFile(manifestPath, current, w.fileMode); err != nil {
+		return nil, errors.Wrapf(err, errFmtWriteGeneratedManifest, manifestPath)
+	}
+	return stale, nil
+}
+
+// generatedFiles returns the paths of the files listed in the manifest of
+// the directory that still exist. Files outside of the directory are never
+// returned, and a directory without manifest has no generated files.
+func (w *directoryWriter) generatedFiles() ([]string, error) {
+	manifestPath := filepath.Join(w.dir, ManifestFile)
+	m := generatedManifest{}
+	b, err := os.ReadFile(manifestPath)
+	switch {
+	case os.IsNotExist(err):
+		return nil, nil
+	case err != nil:
+		return nil, errors.Wrapf(err, errFmtReadGeneratedManifest, manifestPath)
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, errors.Wrapf(err, errFmtReadGeneratedManifest, manifestPath)
+	}
+
+	var files []string
+	for _, f := range m.Files {
+		if !filepath.IsLocal(filepath.FromSlash(f)) {
+			continue
+		}
+		path := filepath.Join(w.dir, filepath.FromSlash(f))
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// manifestContent returns the content of the manifest recording the given
+// generated files.
+func (w *directoryWriter) manifestContent(paths []string) ([]byte, error) {
+	manifestPath := filepath.Join(w.dir, ManifestFile)
+	m := generatedManifest{Files: make([]string, 0, len(paths))}
+	for _, path := range paths {
+		rel, err := filepath.Rel(w.dir, path)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtWriteGeneratedManifest, manifestPath)
+		}
+		m.Files = append(m.Files, filepath.ToSlash(rel))
+	}
+	sort.Strings(m.Files)
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, errors.Wrapf(err, errFmtWriteGeneratedManifest, manifestPath)
+	}
+	return append(b, '\n'), nil
+}
+
+// removeEmptyDirs removes the given directory and its parents below the
+// output directory as long as they are empty.
+func (w *directoryWriter) removeEmptyDirs(dir string) {
+	for dir != w.dir && dir != "." && dir != string(filepath.Separator) {
+		if err := os.Remove(dir); err != nil {
+			return // not empty
+		}
+		dir = filepath.Dir(dir)
+	}
+}
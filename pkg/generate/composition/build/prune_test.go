@@ -0,0 +1,164 @@
+package build
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestDirectoryWriterPrune(t *testing.T) {
+	cases := map[string]struct {
+		manifest     []string
+		files        []string
+		write        []string
+		dryRun       bool
+		wantStale    []string
+		wantFiles    []string
+		wantManifest []string
+	}{
+		"FirstRun": {
+			files:        []string{"crossplane.yaml"},
+			write:        []string{"a"},
+			wantFiles:    []string{"a.yaml", "crossplane.yaml"},
+			wantManifest: []string{"a.yaml"},
+		},
+		"StaleRemoved": {
+			manifest:     []string{"a.yaml", "old/b.yaml"},
+			files:        []string{"a.yaml", "old/b.yaml", "crossplane.yaml"},
+			write:        []string{"a"},
+			wantStale:    []string{"old/b.yaml"},
+			wantFiles:    []string{"a.yaml", "crossplane.yaml"},
+			wantManifest: []string{"a.yaml"},
+		},
+		"UnlistedNeverRemoved": {
+			manifest:     []string{"a.yaml"},
+			files:        []string{"a.yaml", "b.yaml", "sub/c.yaml"},
+			wantStale:    []string{"a.yaml"},
+			wantFiles:    []string{"b.yaml", "sub/c.yaml"},
+			wantManifest: []string{},
+		},
+		"NonEmptyDirectoryKept": {
+			manifest:     []string{"sub/a.yaml"},
+			files:        []string{"sub/a.yaml", "sub/b.yaml"},
+			wantStale:    []string{"sub/a.yaml"},
+			wantFiles:    []string{"sub/b.yaml"},
+			wantManifest: []string{},
+		},
+		"OutsideDirectoryIgnored": {
+			manifest:     []string{"../outside.yaml", "/etc/passwd"},
+			write:        []string{"a"},
+			wantFiles:    []string{"a.yaml"},
+			wantManifest: []string{"a.yaml"},
+		},
+		"MissingFileIgnored": {
+			manifest:     []string{"gone.yaml"},
+			write:        []string{"a"},
+			wantFiles:    []string{"a.yaml"},
+			wantManifest: []string{"a.yaml"},
+		},
+		"DryRun": {
+			manifest:     []string{"a.yaml", "b.yaml"},
+			files:        []string{"a.yaml", "b.yaml"},
+			write:        []string{"a"},
+			dryRun:       true,
+			wantStale:    []string{"b.yaml"},
+			wantFiles:    []string{"a.yaml", "b.yaml"},
+			wantManifest: []string{"a.yaml", "b.yaml"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "apis")
+			for _, f := range append(tc.files, "../outside.yaml") {
+				writeTestFile(t, filepath.Join(dir, f), "kind: Other\n")
+			}
+			if tc.manifest != nil {
+				b, err := json.Marshal(generatedManifest{Files: tc.manifest})
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeTestFile(t, filepath.Join(dir, ManifestFile), string(b))
+			}
+
+			w, err := NewDirectoryWriterFromConfig(DirectoryWriterConfig{Dir: dir, Layout: "{{ .Name }}.yaml"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tc.write {
+				if err := w.Write(testComposition(name, "a.example.org/v1", "XA")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			stale, err := w.Prune(tc.dryRun)
+			if err != nil {
+				t.Fatalf("Prune(): %v", err)
+			}
+			var gotStale []string
+			for _, path := range stale {
+				rel, err := filepath.Rel(dir, path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				gotStale = append(gotStale, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(gotStale, tc.wantStale) {
+				t.Errorf("Prune() = %q, want %q", gotStale, tc.wantStale)
+			}
+
+			if got := testFiles(t, dir); !reflect.DeepEqual(got, tc.wantFiles) {
+				t.Errorf("Prune() left %q, want %q", got, tc.wantFiles)
+			}
+			if _, err := os.Stat(filepath.Join(root, "outside.yaml")); err != nil {
+				t.Errorf("Prune() touched a file outside of the directory: %v", err)
+			}
+
+			b, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := generatedManifest{}
+			if err := json.Unmarshal(b, &m); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(m.Files, tc.wantManifest) {
+				t.Errorf("Prune() recorded %q, want %q", m.Files, tc.wantManifest)
+			}
+		})
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testFiles returns the slash separated paths of the files in the given
+// directory, except for the manifest.
+func testFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() == ManifestFile {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		files = append(files, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
@@ -0,0 +1,244 @@
+package build
+
+import (
+	"math"
+	"reflect"
+
+	xpt "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/mproffitt/crossbuilder/pkg/generate/utils"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	errFmtInvalidReadinessCheck         = "invalid readiness check at index %d"
+	errFmtInvalidResourceReadinessCheck = "invalid readiness checks for resource %q"
+	errReadinessCheckFieldPath          = "fieldPath is invalid"
+	errFmtReadinessCheckType            = "%s readiness check requires a field of type %s but got %s"
+	errFmtUnsupportedReadinessValue     = "cannot match a value of type %T in a readiness check"
+	errFmtReadinessIntegerOverflow      = "cannot match %d in a readiness check, it does not fit into an int64"
+
+	conditionsFieldPath = "status.conditions"
+
+	// readinessCheckTypeInvalid is the type of the readiness checks returned
+	// by invalidReadinessCheck.
+	readinessCheckTypeInvalid xapiextv1.ReadinessCheckType = "Invalid"
+)
+
+// ReadyWhenConditionTrue returns a readiness check that considers a composed
+// resource ready when the condition of the given type is true.
+func ReadyWhenConditionTrue(conditionType xpv1.ConditionType) xapiextv1.ReadinessCheck {
+	return xapiextv1.ReadinessCheck{
+		Type: xapiextv1.ReadinessCheckTypeMatchCondition,
+		MatchCondition: &xapiextv1.MatchConditionReadinessCheck{
+			Type:   conditionType,
+			Status: corev1.ConditionTrue,
+		},
+	}
+}
+
+// ReadyWhenFieldEquals returns a readiness check that considers a composed
+// resource ready when the field at the given path equals the given value.
+// Booleans, strings and integers are supported and the check type is chosen
+// accordingly. Other values, and unsigned integers that do not fit into an
+// int64, result in a check that fails the build of the composition.
+func ReadyWhenFieldEquals(path string, value interface{}) xapiextv1.ReadinessCheck {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Bool:
+		return ReadyWhenFieldEqualsBool(path, v.Bool())
+	case reflect.String:
+		return ReadyWhenFieldEqualsString(path, v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return ReadyWhenFieldEqualsInt(path, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return invalidReadinessCheck(path, errors.Errorf(errFmtReadinessIntegerOverflow, v.Uint()))
+		}
+		return ReadyWhenFieldEqualsInt(path, int64(v.Uint()))
+	default:
+		return invalidReadinessCheck(path, errors.Errorf(errFmtUnsupportedReadinessValue, value))
+	}
+}
+
+// ReadyWhenFieldEqualsBool returns a readiness check that considers a
+// composed resource ready when the boolean field at the given path equals the
+// given value.
+func ReadyWhenFieldEqualsBool(path string, value bool) xapiextv1.ReadinessCheck {
+	check := xapiextv1.ReadinessCheck{
+		Type:      xapiextv1.ReadinessCheckTypeMatchFalse,
+		FieldPath: path,
+	}
+	if value {
+		check.Type = xapiextv1.ReadinessCheckTypeMatchTrue
+	}
+	return check
+}
+
+// ReadyWhenFieldEqualsString returns a readiness check that considers a
+// composed resource ready when the string field at the given path equals the
+// given value.
+func ReadyWhenFieldEqualsString(path string, value string) xapiextv1.ReadinessCheck {
+	return xapiextv1.ReadinessCheck{
+		Type:        xapiextv1.ReadinessCheckTypeMatchString,
+		FieldPath:   path,
+		MatchString: value,
+	}
+}
+
+// ReadyWhenFieldEqualsInt returns a readiness check that considers a
+// composed resource ready when the integer field at the given path equals the
+// given value.
+func ReadyWhenFieldEqualsInt(path string, value int64) xapiextv1.ReadinessCheck {
+	return xapiextv1.ReadinessCheck{
+		Type:         xapiextv1.ReadinessCheckTypeMatchInteger,
+		FieldPath:    path,
+		MatchInteger: value,
+	}
+}
+
+// invalidReadinessCheck returns a readiness check recording that a helper
+// was given a value it cannot match. The check is rejected with the given
+// error when the composed template is validated.
+func invalidReadinessCheck(path string, err error) xapiextv1.ReadinessCheck {
+	return xapiextv1.ReadinessCheck{
+		Type:        readinessCheckTypeInvalid,
+		FieldPath:   path,
+		MatchString: err.Error(),
+	}
+}
+
+// ReadyWhenNonEmpty returns a readiness check that considers a composed
+// resource ready when the field at the given path is set.
+func ReadyWhenNonEmpty(path string) xapiextv1.ReadinessCheck {
+	return xapiextv1.ReadinessCheck{
+		Type:      xapiextv1.ReadinessCheckTypeNonEmpty,
+		FieldPath: path,
+	}
+}
+
+// ReadyOnCreation returns a readiness check that considers a composed
+// resource ready as soon as it has been created, without looking at any of
+// its fields.
+func ReadyOnCreation() xapiextv1.ReadinessCheck {
+	return xapiextv1.ReadinessCheck{
+		Type: xapiextv1.ReadinessCheckTypeNone,
+	}
+}
+
+// NeverReady returns a readiness check of type None. Despite its name, such
+// a check considers a composed resource ready as soon as it has been created.
+//
+// Deprecated: Use ReadyOnCreation, which is named after what the check does.
+func NeverReady() xapiextv1.ReadinessCheck {
+	return ReadyOnCreation()
+}
+
+// validateReadinessChecks validates the given readiness checks and their
+// field paths against the given base.
+func validateReadinessChecks(checks []xapiextv1.ReadinessCheck, base interface{}, knownPaths []fieldpath.Segments) error {
+	for i := range checks {
+		if checks[i].Type == readinessCheckTypeInvalid {
+			return errors.Wrapf(errors.New(checks[i].MatchString), errFmtInvalidReadinessCheck, i)
+		}
+		if err := checks[i].Validate(); err != nil {
+			return errors.Wrapf(err, errFmtInvalidReadinessCheck, i)
+		}
+		if err := validateReadinessCheck(string(checks[i].Type), checks[i].FieldPath, base, knownPaths); err != nil {
+			return errors.Wrapf(err, errFmtInvalidReadinessCheck, i)
+		}
+	}
+	return nil
+}
+
+// validateComposedReadinessChecks validates the given patch-and-transform
+// readiness checks and their field paths against the given base.
+func validateComposedReadinessChecks(checks []xpt.ReadinessCheck, base interface{}, knownPaths []fieldpath.Segments) error {
+	for i, check := range checks {
+		if err := validateComposedReadinessCheck(check); err != nil {
+			return errors.Wrapf(err, errFmtInvalidReadinessCheck, i)
+		}
+		if err := validateReadinessCheck(string(check.Type), utils.StringValue(check.FieldPath), base, knownPaths); err != nil {
+			return errors.Wrapf(err, errFmtInvalidReadinessCheck, i)
+		}
+	}
+	return nil
+}
+
+// validateComposedReadinessCheck checks the given patch-and-transform
+// readiness check the same way ReadinessCheck.Validate checks the readiness
+// checks of Resources mode compositions. Unlike there, the match values are
+// pointers, so only unset values are rejected.
+func validateComposedReadinessCheck(check xpt.ReadinessCheck) *field.Error {
+	if !check.Type.IsValid() {
+		return field.Invalid(field.NewPath("type"), string(check.Type), "unknown readiness check type")
+	}
+	switch check.Type {
+	case xpt.ReadinessCheckTypeNone:
+		return nil
+	case xpt.ReadinessCheckTypeMatchString:
+		if check.MatchString == nil {
+			return field.Required(field.NewPath("matchString"), "cannot be empty for type MatchString")
+		}
+	case xpt.ReadinessCheckTypeMatchInteger:
+		if check.MatchInteger == nil {
+			return field.Required(field.NewPath("matchInteger"), "cannot be empty for type MatchInteger")
+		}
+	case xpt.ReadinessCheckTypeMatchCondition:
+		if check.MatchCondition == nil {
+			return field.Required(field.NewPath("matchCondition"), "cannot be empty for type MatchCondition")
+		}
+		mc := xapiextv1.MatchConditionReadinessCheck{
+			Type:   check.MatchCondition.Type,
+			Status: check.MatchCondition.Status,
+		}
+		if err := mc.Validate(); err != nil {
+			err.Field = field.NewPath("matchCondition").Child(err.Field).String()
+			return err
+		}
+		return nil
+	}
+	if utils.StringValue(check.FieldPath) == "" {
+		return field.Required(field.NewPath("fieldPath"), "cannot be empty")
+	}
+	return nil
+}
+
+// validateReadinessCheck validates the field a readiness check of the given
+// type reads exists on the given base and holds a value the check can match.
+// Condition checks require the base to have status conditions.
+func validateReadinessCheck(checkType, path string, base interface{}, knownPaths []fieldpath.Segments) error {
+	if !canValidate(base) {
+		return nil
+	}
+
+	var expected valueKind
+	switch xapiextv1.ReadinessCheckType(checkType) {
+	case xapiextv1.ReadinessCheckTypeMatchString:
+		expected = valueKindString
+	case xapiextv1.ReadinessCheckTypeMatchInteger:
+		expected = valueKindInteger
+	case xapiextv1.ReadinessCheckTypeMatchTrue, xapiextv1.ReadinessCheckTypeMatchFalse:
+		expected = valueKindBoolean
+	case xapiextv1.ReadinessCheckTypeNonEmpty:
+		expected = valueKindAny
+	case xapiextv1.ReadinessCheckTypeMatchCondition:
+		path = conditionsFieldPath
+		expected = valueKindArray
+	default:
+		return nil
+	}
+
+	field, err := fieldPathKind(base, path, knownPaths)
+	if err != nil {
+		return errors.Wrap(err, errReadinessCheckFieldPath)
+	}
+	if !isAssignable(expected, field) {
+		return errors.Errorf(errFmtReadinessCheckType, checkType, expected, field)
+	}
+	return nil
+}
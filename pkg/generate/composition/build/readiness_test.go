@@ -0,0 +1,204 @@
+package build
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	xpt "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestReadyWhenFieldEquals(t *testing.T) {
+	cases := map[string]struct {
+		value   interface{}
+		want    xapiextv1.ReadinessCheck
+		wantErr string
+	}{
+		"True": {
+			value: true,
+			want:  xapiextv1.ReadinessCheck{Type: xapiextv1.ReadinessCheckTypeMatchTrue, FieldPath: "status.x"},
+		},
+		"False": {
+			value: false,
+			want:  xapiextv1.ReadinessCheck{Type: xapiextv1.ReadinessCheckTypeMatchFalse, FieldPath: "status.x"},
+		},
+		"String": {
+			value: "ok",
+			want:  xapiextv1.ReadinessCheck{Type: xapiextv1.ReadinessCheckTypeMatchString, FieldPath: "status.x", MatchString: "ok"},
+		},
+		"Int": {
+			value: int32(-3),
+			want:  xapiextv1.ReadinessCheck{Type: xapiextv1.ReadinessCheckTypeMatchInteger, FieldPath: "status.x", MatchInteger: -3},
+		},
+		"Uint": {
+			value: uint16(3),
+			want:  xapiextv1.ReadinessCheck{Type: xapiextv1.ReadinessCheckTypeMatchInteger, FieldPath: "status.x", MatchInteger: 3},
+		},
+		"MaxUint": {
+			value: uint64(math.MaxInt64),
+			want:  xapiextv1.ReadinessCheck{Type: xapiextv1.ReadinessCheckTypeMatchInteger, FieldPath: "status.x", MatchInteger: math.MaxInt64},
+		},
+		"UintOverflow": {
+			value:   uint64(math.MaxInt64) + 1,
+			wantErr: "does not fit into an int64",
+		},
+		"Float": {
+			value:   1.5,
+			wantErr: "cannot match a value of type float64 in a readiness check",
+		},
+		"Nil": {
+			wantErr: "cannot match a value of type <nil> in a readiness check",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ReadyWhenFieldEquals("status.x", tc.value)
+			err := validateReadinessChecks([]xapiextv1.ReadinessCheck{got}, nil, nil)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("ReadyWhenFieldEquals() is invalid: %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("ReadyWhenFieldEquals() is invalid with %v, want error containing %q", err, tc.wantErr)
+			case tc.wantErr == "" && !reflect.DeepEqual(got, tc.want):
+				t.Errorf("ReadyWhenFieldEquals() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateReadinessChecks(t *testing.T) {
+	cases := map[string]struct {
+		check   xapiextv1.ReadinessCheck
+		wantErr string
+	}{
+		"ConditionTrue": {
+			check: ReadyWhenConditionTrue(xpv1.TypeReady),
+		},
+		"MatchString": {
+			check: ReadyWhenFieldEquals("status.phase", "Running"),
+		},
+		"MatchStringOnBoolean": {
+			check:   ReadyWhenFieldEquals("spec.hostNetwork", "yes"),
+			wantErr: "MatchString readiness check requires a field of type string but got boolean",
+		},
+		"MatchTrue": {
+			check: ReadyWhenFieldEquals("spec.hostNetwork", true),
+		},
+		"NonEmptyUnknownField": {
+			check:   ReadyWhenNonEmpty("status.nope"),
+			wantErr: "fieldPath is invalid",
+		},
+		"EmptyMatchString": {
+			check:   xapiextv1.ReadinessCheck{Type: xapiextv1.ReadinessCheckTypeMatchString, FieldPath: "status.phase"},
+			wantErr: "matchString",
+		},
+		"UnknownType": {
+			check:   xapiextv1.ReadinessCheck{Type: "Maybe"},
+			wantErr: "unknown readiness check type",
+		},
+		"ReadyOnCreation": {
+			check: ReadyOnCreation(),
+		},
+		"NeverReady": {
+			check: NeverReady(),
+		},
+		"MatchIntegerOnString": {
+			check:   ReadyWhenFieldEqualsInt("status.phase", 1),
+			wantErr: "MatchInteger readiness check requires a field of type integer but got string",
+		},
+		"UnsupportedValue": {
+			check:   ReadyWhenFieldEquals("status.phase", 1.5),
+			wantErr: "invalid readiness check at index 0: cannot match a value of type float64 in a readiness check",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateReadinessChecks([]xapiextv1.ReadinessCheck{tc.check}, &corev1.Pod{}, nil)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("validateReadinessChecks(): %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("validateReadinessChecks() = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateComposedReadinessChecks(t *testing.T) {
+	str := func(s string) *string { return &s }
+	integer := func(i int64) *int64 { return &i }
+
+	cases := map[string]struct {
+		check   xpt.ReadinessCheck
+		wantErr string
+	}{
+		"None": {
+			check: xpt.ReadinessCheck{Type: xpt.ReadinessCheckTypeNone},
+		},
+		"UnknownType": {
+			check:   xpt.ReadinessCheck{Type: "Maybe"},
+			wantErr: `type: Invalid value: "Maybe": unknown readiness check type`,
+		},
+		"MatchString": {
+			check: xpt.ReadinessCheck{Type: xpt.ReadinessCheckTypeMatchString, FieldPath: str("status.phase"), MatchString: str("Running")},
+		},
+		"MatchEmptyString": {
+			check: xpt.ReadinessCheck{Type: xpt.ReadinessCheckTypeMatchString, FieldPath: str("status.phase"), MatchString: str("")},
+		},
+		"MatchStringUnset": {
+			check:   xpt.ReadinessCheck{Type: xpt.ReadinessCheckTypeMatchString, FieldPath: str("status.phase")},
+			wantErr: "matchString: Required value",
+		},
+		"MatchIntegerZero": {
+			check: xpt.ReadinessCheck{Type: xpt.ReadinessCheckTypeMatchInteger, FieldPath: str("spec.priority"), MatchInteger: integer(0)},
+		},
+		"MatchIntegerUnset": {
+			check:   xpt.ReadinessCheck{Type: xpt.ReadinessCheckTypeMatchInteger, FieldPath: str("spec.priority")},
+			wantErr: "matchInteger: Required value",
+		},
+		"MatchIntegerOnString": {
+			check:   xpt.ReadinessCheck{Type: xpt.ReadinessCheckTypeMatchInteger, FieldPath: str("status.phase"), MatchInteger: integer(1)},
+			wantErr: "MatchInteger readiness check requires a field of type integer but got string",
+		},
+		"MatchCondition": {
+			check: xpt.ReadinessCheck{
+				Type:           xpt.ReadinessCheckTypeMatchCondition,
+				MatchCondition: &xpt.MatchConditionReadinessCheck{Type: xpv1.TypeReady, Status: corev1.ConditionTrue},
+			},
+		},
+		"MatchConditionUnset": {
+			check:   xpt.ReadinessCheck{Type: xpt.ReadinessCheckTypeMatchCondition},
+			wantErr: "matchCondition: Required value",
+		},
+		"MatchConditionWithoutStatus": {
+			check: xpt.ReadinessCheck{
+				Type:           xpt.ReadinessCheckTypeMatchCondition,
+				MatchCondition: &xpt.MatchConditionReadinessCheck{Type: xpv1.TypeReady},
+			},
+			wantErr: "matchCondition.status: Required value",
+		},
+		"NonEmptyWithoutFieldPath": {
+			check:   xpt.ReadinessCheck{Type: xpt.ReadinessCheckTypeNonEmpty},
+			wantErr: "fieldPath: Required value",
+		},
+		"MatchTrueUnknownField": {
+			check:   xpt.ReadinessCheck{Type: xpt.ReadinessCheckTypeMatchTrue, FieldPath: str("status.nope")},
+			wantErr: "fieldPath is invalid",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateComposedReadinessChecks([]xpt.ReadinessCheck{tc.check}, &corev1.Pod{}, nil)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("validateComposedReadinessChecks(): %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("validateComposedReadinessChecks() = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package build
+
+import (
+	xgt "github.com/crossplane-contrib/function-go-templating/input/v1beta1"
+	xpt "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	xapiext "github.com/crossplane/crossplane/apis/apiextensions"
+	xpkg "github.com/crossplane/crossplane/apis/pkg"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+
+	xenv "github.com/mproffitt/crossbuilder/pkg/generate/composition/input/environmentconfigs/v1beta1"
+	xer "github.com/mproffitt/crossbuilder/pkg/generate/composition/input/extraresources/v1beta1"
+	xkcl "github.com/mproffitt/crossbuilder/pkg/generate/composition/input/kcl/v1alpha1"
+)
+
+const (
+	errFmtTypeNotRegistered = "type %T is not registered, add it with build.AddToScheme"
+	errAddToScheme          = "cannot add types to scheme"
+)
+
+// scheme holds the types crossbuilder is able to infer the GroupVersionKind
+// for.
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(AddToScheme(
+		clientgoscheme.AddToScheme,
+		xapiext.AddToScheme,
+		xpkg.AddToScheme,
+		xenv.AddToScheme,
+		xer.AddToScheme,
+		xkcl.AddToScheme,
+		func(s *runtime.Scheme) error {
+			s.AddKnownTypeWithName(GoTemplateGroupVersionKind, &xgt.GoTemplate{})
+			s.AddKnownTypeWithName(ResourcesGroupVersionKind, &xpt.Resources{})
+			return nil
+		},
+	))
+}
+
+// AddToScheme registers additional types so their GroupVersionKind can be
+// inferred for resource bases, step inputs and composite types. It accepts
+// the AddToScheme functions generated for API packages.
+func AddToScheme(funcs ...func(*runtime.Scheme) error) error {
+	for _, f := range funcs {
+		if err := f(scheme); err != nil {
+			return errors.Wrap(err, errAddToScheme)
+		}
+	}
+	return nil
+}
+
+// GroupVersionKindFor returns the GroupVersionKind of the given object. If
+// the object carries its own type information this is returned, otherwise
+// the GroupVersionKind is looked up in the scheme.
+func GroupVersionKindFor(obj runtime.Object) (schema.GroupVersionKind, error) {
+	if obj == nil {
+		return schema.GroupVersionKind{}, errors.New(errNilObject)
+	}
+	if gvk := obj.GetObjectKind().GroupVersionKind(); !gvk.Empty() {
+		return gvk, nil
+	}
+
+	gvks, _, err := scheme.ObjectKinds(obj)
+	if err != nil || len(gvks) == 0 {
+		return schema.GroupVersionKind{}, errors.Errorf(errFmtTypeNotRegistered, obj)
+	}
+	return gvks[0], nil
+}
@@ -12,6 +12,8 @@ const (
 	errFmtInvalidPatch                      = "invalid patch at index %d"
 	errPatchFromFieldPath                   = "fromFieldPath is invalid"
 	errPatchToFieldPath                     = "toFieldPath is invalid"
+	errPatchTypes                           = "incompatible types"
+	errConvertPatch                         = "cannot convert patch"
 	errPatchRequireField                    = "missing field %s"
 	errPatchCombineEmptyVariables           = "no variables given"
 	errFmtPatchCombineVariableFromFieldPath = "fromFieldPath of variable at index %d is invalid"
@@ -22,7 +24,23 @@ const (
 	errInvalidResourcesMode                 = "invalid mode for resources composition"
 	errFmtSetupComposition                  = "failed to setup composition"
 	errFmtInvalidPatchAndTransform          = "invalid patch-and-transform function ref"
+	errFmtInvalidPipelineStep               = "invalid pipeline step %q at index %d"
+	errFmtStepNotFound                      = "no pipeline step named %q"
+	errFmtPatchResourceNotFound             = "no resource named %q in patch-and-transform input"
+	errFmtUnexpectedPatchInput              = "patches require an input of type %T but got %T"
 	errNilObject                            = "object must not be nil"
+	errResolveCompositeType                 = "cannot resolve composite type"
+	errResolveBaseType                      = "cannot resolve base type"
+	errResolveInputType                     = "cannot resolve input type"
+	errFmtResolveResourceBaseType           = "cannot resolve base type of resource %q"
+	errFmtInvalidResourcePatch              = "invalid patches for resource %q"
+	errFmtUnknownPatchSet                   = "no patch set named %q"
+	errFmtInvalidPatchSetPatch              = "invalid patch at index %d of patch set %q"
+	errNestedPatchSet                       = "patch sets must not contain patches of type PatchSet"
+	errPatchSetsInPipelineMode              = "patch sets cannot be used in pipeline mode, use a patch-and-transform step instead"
+	errParseRegisteredEnvironmentPaths      = "cannot parse registered environment paths"
+	errInvalidEnvironment                   = "invalid environment"
+	errFmtInvalidEnvironmentPatch           = "invalid environment patch at index %d"
 
 	labelKeyClaimName      = "crossplane.io/claim-name"
 	labelKeyClaimNamespace = "crossplane.io/claim-namespace"
@@ -75,11 +93,16 @@ type ComposedTemplateSkeleton interface {
 	RegisterAnnotations(annotationKeys ...string) ComposedTemplateSkeleton
 
 	// RegisterLabels marks the given resource label as safe
-	// so they will be treated as a valid field in patch paths.
+	// so they will be treated as a valid field in patch paths. Use * to
+	// register all labels.
 	RegisterLabels(labelsKeys ...string) ComposedTemplateSkeleton
 
 	// RegisterFieldPaths marks the given resource paths as safe so ti will
 	// be treated a valid in patch paths.
+	//
+	// Paths may contain wildcards such as spec.tags[*].value, which match any
+	// single field or index. Registering a path also registers all paths
+	// below it.
 	RegisterFieldPaths(paths ...string) ComposedTemplateSkeleton
 }
 
@@ -100,9 +123,41 @@ type CompositionSkeleton interface {
 	// NewResource creates a new ComposedTemplateSkeleton with the given base.
 	NewResource(base ObjectKindReference) ComposedTemplateSkeleton
 
-	// WithPipeline adds the following pipeline to this compositionSkeleton.
+	// WithEnvironment sets the environment of the composition to be built.
+	WithEnvironment(env xapiextv1.EnvironmentConfiguration) CompositionSkeleton
+
+	// WithEnvironmentType sets a Go type describing the data of the
+	// environment. Environment paths in patches are validated against it.
+	// They are not validated if no type has been set.
+	WithEnvironmentType(obj interface{}) CompositionSkeleton
+
+	// WithPatchSet adds the following patches to the patch set with the
+	// given name. Composed resources refer to patch sets with patches of type
+	// PatchSet. Patch sets are only supported in Resources mode.
+	WithPatchSet(name string, patches ...xapiextv1.Patch) CompositionSkeleton
+
+	// NewPipelineStep appends a new step with the given name to the pipeline.
 	NewPipelineStep(step string) PipelineStepSkeleton
 
+	// InsertStepBefore inserts a new step with the given name directly
+	// before the existing step named before.
+	InsertStepBefore(before, step string) PipelineStepSkeleton
+
+	// InsertStepAfter inserts a new step with the given name directly after
+	// the existing step named after.
+	InsertStepAfter(after, step string) PipelineStepSkeleton
+
+	// ReplaceStep replaces the existing step with the given name by a new,
+	// empty step at the same position.
+	ReplaceStep(step string) PipelineStepSkeleton
+
+	// RemoveStep removes the step with the given name from the pipeline.
+	RemoveStep(step string) CompositionSkeleton
+
+	// GetStep returns the step with the given name. Building the
+	// composition fails if there is no such step.
+	GetStep(step string) PipelineStepSkeleton
+
 	// WithPublishConnectionDetailsWithStoreConfig sets the
 	// PublishConnectionDetailsWithStoreConfig of this CompositionSkeleton.
 	WithPublishConnectionDetailsWithStoreConfig(ref *xapiextv1.StoreConfigReference) CompositionSkeleton
@@ -120,8 +175,14 @@ type CompositionSkeleton interface {
 	RegisterCompositeLabels(labelKeys ...string) CompositionSkeleton
 
 	// RegisterCompositeFieldPaths marks the given composite paths as safe so
-	// they will be treated a valid in patch paths.
+	// they will be treated a valid in patch paths. Paths may contain
+	// wildcards and register all paths below them, see RegisterFieldPaths.
 	RegisterCompositeFieldPaths(paths ...string) CompositionSkeleton
+
+	// RegisterEnvironmentFieldPaths marks the given environment paths as safe
+	// so they will be treated as valid in patch paths. Paths may contain
+	// wildcards and register all paths below them, see RegisterFieldPaths.
+	RegisterEnvironmentFieldPaths(paths ...string) CompositionSkeleton
 }
 
 // PipelineStepSkeleton represents the build time state of a pipeline step.
@@ -133,12 +194,41 @@ type PipelineStepSkeleton interface {
 	// WithInput sets the input of this pipeline step.
 	WithInput(input ObjectKindReference) PipelineStepSkeleton
 
-	// WithPatches adds the following patches to this pipeline step.
+	// WithFunction sets the function reference and input of this pipeline
+	// step from the given FunctionStep. Takes precedence over
+	// WithFunctionRef and WithInput.
+	WithFunction(fn FunctionStep) PipelineStepSkeleton
+
+	// WithCredentials adds the following credentials to this pipeline step.
+	WithCredentials(credentials ...xapiextv1.FunctionCredentials) PipelineStepSkeleton
+
+	// WithSecretCredentials adds credentials with the given name to this
+	// pipeline step which are read from the referenced secret.
+	WithSecretCredentials(name, namespace, secretName string) PipelineStepSkeleton
+
+	// AlwaysFirst pins this step to the start of the pipeline, regardless of
+	// the order steps are added in. Pinned steps keep their relative order.
+	AlwaysFirst() PipelineStepSkeleton
+
+	// AlwaysLast pins this step to the end of the pipeline, regardless of the
+	// order steps are added in. Pinned steps keep their relative order.
+	AlwaysLast() PipelineStepSkeleton
+
+	// WithPatches adds the following patches to the resource with the given
+	// name in the patch-and-transform input of this pipeline step.
 	//
 	// Will automatically register the `patch-and-transform` function if not
-	// already registered.
+	// already registered. Building the step fails if the input does not
+	// contain a resource with the given name.
+	//
+	// If both the composite and the base of the resource are Go types, the
+	// field paths of the patches are validated against them.
 	WithPatches(name string, patches ...xpt.ComposedPatch) PipelineStepSkeleton
 
 	// WithPatch adds the following patch to this pipeline step.
 	WithPatch(name string, patch xpt.ComposedPatch) PipelineStepSkeleton
+
+	// WithUnsafePatches is similar to WithPatches but the field paths of the
+	// patches will not be validated.
+	WithUnsafePatches(name string, patches ...xpt.ComposedPatch) PipelineStepSkeleton
 }
@@ -5,11 +5,30 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode"
 )
 
 var templates []string
 var TemplateBasePath string
 
+// templateSource records the file a part of a loaded template was read from
+// and the line of the loaded template that part starts at.
+type templateSource struct {
+	file string
+	line int
+}
+
+// loadedTemplate is a template returned by LoadTemplate and the files it was
+// read from.
+type loadedTemplate struct {
+	text    string
+	sources []templateSource
+}
+
+// loadedTemplates holds the last template loaded from each path so errors can
+// refer to the original file and line.
+var loadedTemplates = map[string]loadedTemplate{}
+
 func AddTemplate(path string) {
 	templates = append(templates, path)
 }
@@ -20,6 +39,7 @@ func SetBasePath(path string) {
 
 func LoadTemplate(path string) (string, error) {
 	path = strings.TrimSuffix(path, "*")
+	key := filepath.Join(TemplateBasePath, path)
 
 	templates = make([]string, 0)
 
@@ -31,7 +51,10 @@ func LoadTemplate(path string) (string, error) {
 		}
 	}
 
-	var contents string
+	var (
+		contents string
+		sources  = make([]templateSource, 0, len(templates))
+	)
 	for _, t := range templates {
 		if _, err := os.Stat(t); err != nil {
 			return "", err
@@ -46,13 +69,41 @@ func LoadTemplate(path string) (string, error) {
 		if b, err = os.ReadFile(t); err != nil {
 			return "", err
 		}
+		sources = append(sources, templateSource{
+			file: t,
+			line: strings.Count(contents, "\n") + 2,
+		})
 		contents = strings.Join([]string{contents, string(b)}, "\n")
 	}
 
+	trimmed := strings.Count(contents[:len(contents)-len(strings.TrimLeftFunc(contents, unicode.IsSpace))], "\n")
+	for i := range sources {
+		sources[i].line -= trimmed
+	}
+
 	contents = strings.TrimSpace(contents)
+	// The same template may be loaded from more than one path, refer errors
+	// to the files it was loaded from last.
+	for k, t := range loadedTemplates {
+		if t.text == contents {
+			delete(loadedTemplates, k)
+		}
+	}
+	loadedTemplates[key] = loadedTemplate{text: contents, sources: sources}
 	return contents, nil
 }
 
+// templateSources returns the files the given template was loaded from with
+// LoadTemplate.
+func templateSources(text string) []templateSource {
+	for _, t := range loadedTemplates {
+		if t.text == text {
+			return t.sources
+		}
+	}
+	return nil
+}
+
 func walk(s string, d fs.DirEntry, err error) error {
 	if err != nil {
 		return err
@@ -0,0 +1,86 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadTemplateSources(t *testing.T) {
+	cases := map[string]struct {
+		files map[string]string
+		path  string
+		want  string
+		lines map[int]string
+	}{
+		"SingleFile": {
+			files: map[string]string{"a.tpl": "one\ntwo\n"},
+			path:  "a.tpl",
+			want:  "one\ntwo",
+			lines: map[int]string{1: "a.tpl:1", 2: "a.tpl:2"},
+		},
+		"LeadingNewlinesTrimmed": {
+			files: map[string]string{"a.tpl": "\n\n  one\ntwo"},
+			path:  "a.tpl",
+			want:  "one\ntwo",
+			lines: map[int]string{1: "a.tpl:3", 2: "a.tpl:4"},
+		},
+		"Directory": {
+			files: map[string]string{
+				"dir/01.tpl": "one\ntwo\n",
+				"dir/02.tpl": "three",
+			},
+			path: "dir/*",
+			want: "one\ntwo\n\nthree",
+			lines: map[int]string{
+				1: "dir/01.tpl:1",
+				2: "dir/01.tpl:2",
+				3: "dir/01.tpl:3",
+				4: "dir/02.tpl:1",
+			},
+		},
+		"DirectoryLeadingBlankFile": {
+			files: map[string]string{
+				"dir/01.tpl": "\none",
+				"dir/02.tpl": "two\nthree",
+			},
+			path: "dir/*",
+			want: "one\ntwo\nthree",
+			lines: map[int]string{
+				1: "dir/01.tpl:2",
+				2: "dir/02.tpl:1",
+				3: "dir/02.tpl:2",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			for f, content := range tc.files {
+				p := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			SetBasePath(dir)
+			defer SetBasePath("")
+
+			got, err := LoadTemplate(tc.path)
+			if err != nil {
+				t.Fatalf("LoadTemplate(%q): %v", tc.path, err)
+			}
+			if got != tc.want {
+				t.Errorf("LoadTemplate(%q) = %q, want %q", tc.path, got, tc.want)
+			}
+			for line, want := range tc.lines {
+				want = filepath.Join(dir, want)
+				if got := templateSourceOf(got, line); got != want {
+					t.Errorf("templateSourceOf(%d) = %q, want %q", line, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+const (
+	errFmtInvalidTransform          = "transform at index %d is invalid"
+	errFmtIncompatiblePatchTypes    = "cannot patch a value of type %s into a field of type %s"
+	errFmtTransformInputType        = "%s transform requires an input of type %s but got %s"
+	errFmtUnsupportedConversion     = "conversion from %s to %s is not supported"
+	errFmtUnknownTransformType      = "unknown transform type %s"
+	errFmtTransformMissingConfig    = "%s transform requires configuration"
+	errFmtUnknownConvertTransformTo = "unknown convert transform type %s"
+	errFmtTransformScalarInput      = "%s transform requires a scalar input but got %s"
+	errFmtUnknownStringTransform    = "unknown string transform type %s"
+	errFmtUnknownStringConversion   = "unknown string conversion type %s"
+
+	fmtStringTransform = "string %s"
+)
+
+// valueKind is the kind of JSON value a field holds or a transform produces.
+type valueKind string
+
+const (
+	valueKindAny     valueKind = "any"
+	valueKindString  valueKind = "string"
+	valueKindInteger valueKind = "integer"
+	valueKindNumber  valueKind = "number"
+	valueKindBoolean valueKind = "boolean"
+	valueKindObject  valueKind = "object"
+	valueKindArray   valueKind = "array"
+
+	// valueKindQuantity is a resource quantity which is written as string
+	// but may be set from a string or a number.
+	valueKindQuantity valueKind = "quantity"
+
+	// valueKindIntOrString may hold an integer or a string.
+	valueKindIntOrString valueKind = "int-or-string"
+)
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// kindOf returns the kind of JSON value the given type is serialized to.
+// Types with custom JSON serialization are treated as any value.
+func kindOf(t reflect.Type) valueKind {
+	if t == nil {
+		return valueKindAny
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if kind, ok := leafTypes[t]; ok {
+		return kind
+	}
+	if hasCustomJSON(t) {
+		return valueKindAny
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return valueKindString
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return valueKindInteger
+	case reflect.Float32, reflect.Float64:
+		return valueKindNumber
+	case reflect.Bool:
+		return valueKindBoolean
+	case reflect.Struct, reflect.Map:
+		return valueKindObject
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return valueKindString // base64 encoded
+		}
+		return valueKindArray
+	}
+	return valueKindAny
+}
+
+// kindOfJSON returns the kind of the given JSON value.
+func kindOfJSON(v extv1.JSON) valueKind {
+	var value interface{}
+	if err := json.Unmarshal(v.Raw, &value); err != nil {
+		return valueKindAny
+	}
+	switch val := value.(type) {
+	case string:
+		return valueKindString
+	case float64:
+		if val == math.Trunc(val) {
+			return valueKindInteger
+		}
+		return valueKindNumber
+	case bool:
+		return valueKindBoolean
+	case map[string]interface{}:
+		return valueKindObject
+	case []interface{}:
+		return valueKindArray
+	}
+	return valueKindAny
+}
+
+// commonKind returns the kind shared by all given kinds or any if they differ.
+func commonKind(kinds ...valueKind) valueKind {
+	if len(kinds) == 0 {
+		return valueKindAny
+	}
+	common := kinds[0]
+	for _, k := range kinds[1:] {
+		switch {
+		case k == common:
+		case isNumeric(k) && isNumeric(common):
+			common = valueKindNumber
+		default:
+			return valueKindAny
+		}
+	}
+	return common
+}
+
+func isNumeric(k valueKind) bool {
+	return k == valueKindInteger || k == valueKindNumber
+}
+
+// isAssignable returns true if a value of the given kind can be patched into
+// a field of the given kind.
+func isAssignable(value, field valueKind) bool {
+	switch {
+	case value == valueKindAny, field == valueKindAny, value == field:
+		return true
+	case value == valueKindInteger && field == valueKindNumber:
+		return true
+	case field == valueKindQuantity:
+		return value == valueKindString || isNumeric(value)
+	case field == valueKindIntOrString:
+		return value == valueKindString || value == valueKindInteger
+	}
+	return false
+}
+
+// valueKindOf returns the kind of value read from a field of the given kind.
+func valueKindOf(field valueKind) valueKind {
+	switch field {
+	case valueKindQuantity:
+		return valueKindString
+	case valueKindIntOrString:
+		return valueKindAny
+	}
+	return field
+}
+
+// validatePatchTypes simulates the given transforms on a value of the given
+// kind and checks the result can be patched into a field of the given kind.
+func validatePatchTypes(in valueKind, transforms []xapiextv1.Transform, field valueKind) error {
+	out := valueKindOf(in)
+	for i := range transforms {
+		var err error
+		if out, err = transformKind(out, &transforms[i]); err != nil {
+			return errors.Wrapf(err, errFmtInvalidTransform, i)
+		}
+	}
+	if !isAssignable(out, field) {
+		return errors.Errorf(errFmtIncompatiblePatchTypes, out, field)
+	}
+	return nil
+}
+
+// transformKind returns the kind of value the given transform produces for
+// an input of the given kind.
+func transformKind(in valueKind, t *xapiextv1.Transform) (valueKind, error) {
+	switch t.Type {
+	case xapiextv1.TransformTypeMap:
+		if t.Map == nil {
+			return "", errors.Errorf(errFmtTransformMissingConfig, t.Type)
+		}
+		if !isAssignable(in, valueKindString) {
+			return "", errors.Errorf(errFmtTransformInputType, t.Type, valueKindString, in)
+		}
+		kinds := make([]valueKind, 0, len(t.Map.Pairs))
+		for _, v := range t.Map.Pairs {
+			kinds = append(kinds, kindOfJSON(v))
+		}
+		return commonKind(kinds...), nil
+	case xapiextv1.TransformTypeMatch:
+		if t.Match == nil {
+			return "", errors.Errorf(errFmtTransformMissingConfig, t.Type)
+		}
+		if !isAssignable(in, valueKindString) {
+			return "", errors.Errorf(errFmtTransformInputType, t.Type, valueKindString, in)
+		}
+		kinds := make([]valueKind, 0, len(t.Match.Patterns)+1)
+		for _, p := range t.Match.Patterns {
+			kinds = append(kinds, kindOfJSON(p.Result))
+		}
+		switch {
+		case t.Match.FallbackTo == xapiextv1.MatchFallbackToTypeInput:
+			kinds = append(kinds, in)
+		case len(t.Match.FallbackValue.Raw) > 0:
+			kinds = append(kinds, kindOfJSON(t.Match.FallbackValue))
+		}
+		return commonKind(kinds...), nil
+	case xapiextv1.TransformTypeMath:
+		if in != valueKindAny && !isNumeric(in) {
+			return "", errors.Errorf(errFmtTransformInputType, t.Type, valueKindNumber, in)
+		}
+		return in, nil
+	case xapiextv1.TransformTypeString:
+		if t.String == nil {
+			return "", errors.Errorf(errFmtTransformMissingConfig, t.Type)
+		}
+		if err := validateStringTransformInput(in, t.String); err != nil {
+			return "", err
+		}
+		return valueKindString, nil
+	case xapiextv1.TransformTypeConvert:
+		if t.Convert == nil {
+			return "", errors.Errorf(errFmtTransformMissingConfig, t.Type)
+		}
+		return convertKind(in, t.Convert.ToType)
+	}
+	return "", errors.Errorf(errFmtUnknownTransformType, t.Type)
+}
+
+// validateStringTransformInput checks the given string transform can be
+// applied to an input of the given kind. Except for Join, Format and the
+// JSON and hash conversions, string transforms format their input as string
+// first, which is only meaningful for strings. Format accepts any scalar.
+func validateStringTransformInput(in valueKind, t *xapiextv1.StringTransform) error {
+	name := fmt.Sprintf(fmtStringTransform, t.Type)
+	want := valueKindString
+	switch t.Type {
+	case xapiextv1.StringTransformTypeJoin:
+		if t.Join == nil {
+			return errors.Errorf(errFmtTransformMissingConfig, name)
+		}
+		want = valueKindArray
+	case xapiextv1.StringTransformTypeFormat:
+		if t.Format == nil {
+			return errors.Errorf(errFmtTransformMissingConfig, name)
+		}
+		if in == valueKindObject || in == valueKindArray {
+			return errors.Errorf(errFmtTransformScalarInput, name, in)
+		}
+		return nil
+	case xapiextv1.StringTransformTypeTrimPrefix, xapiextv1.StringTransformTypeTrimSuffix:
+		if t.Trim == nil {
+			return errors.Errorf(errFmtTransformMissingConfig, name)
+		}
+	case xapiextv1.StringTransformTypeRegexp:
+		if t.Regexp == nil {
+			return errors.Errorf(errFmtTransformMissingConfig, name)
+		}
+	case xapiextv1.StringTransformTypeConvert:
+		if t.Convert == nil {
+			return errors.Errorf(errFmtTransformMissingConfig, name)
+		}
+		switch *t.Convert {
+		case xapiextv1.StringConversionTypeToUpper, xapiextv1.StringConversionTypeToLower,
+			xapiextv1.StringConversionTypeToBase64, xapiextv1.StringConversionTypeFromBase64:
+		case xapiextv1.StringConversionTypeToJSON, xapiextv1.StringConversionTypeToSHA1,
+			xapiextv1.StringConversionTypeToSHA256, xapiextv1.StringConversionTypeToSHA512,
+			xapiextv1.StringConversionTypeToAdler32:
+			return nil // any input is marshalled to JSON
+		default:
+			return errors.Errorf(errFmtUnknownStringConversion, *t.Convert)
+		}
+		name = fmt.Sprintf(fmtStringTransform, *t.Convert)
+	default:
+		return errors.Errorf(errFmtUnknownStringTransform, t.Type)
+	}
+	if !isAssignable(in, want) {
+		return errors.Errorf(errFmtTransformInputType, name, want, in)
+	}
+	return nil
+}
+
+// convertKind returns the kind of value a convert transform to the given type
+// produces for an input of the given kind.
+func convertKind(in valueKind, to xapiextv1.TransformIOType) (valueKind, error) {
+	var out valueKind
+	switch to {
+	case xapiextv1.TransformIOTypeString:
+		out = valueKindString
+	case xapiextv1.TransformIOTypeInt, xapiextv1.TransformIOTypeInt64:
+		out = valueKindInteger
+	case xapiextv1.TransformIOTypeFloat64:
+		out = valueKindNumber
+	case xapiextv1.TransformIOTypeBool:
+		out = valueKindBoolean
+	case xapiextv1.TransformIOTypeObject:
+		out = valueKindObject
+	case xapiextv1.TransformIOTypeArray:
+		out = valueKindArray
+	default:
+		return "", errors.Errorf(errFmtUnknownConvertTransformTo, to)
+	}
+
+	// Objects and arrays can only be parsed from JSON strings and cannot be
+	// converted to anything else.
+	switch {
+	case in == valueKindAny, in == out:
+	case in == valueKindObject, in == valueKindArray:
+		return "", errors.Errorf(errFmtUnsupportedConversion, in, out)
+	case (out == valueKindObject || out == valueKindArray) && in != valueKindString:
+		return "", errors.Errorf(errFmtUnsupportedConversion, in, out)
+	}
+	return out, nil
+}
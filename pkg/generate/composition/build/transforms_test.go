@@ -0,0 +1,314 @@
+package build
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestKindOf(t *testing.T) {
+	cases := map[string]struct {
+		value interface{}
+		want  valueKind
+	}{
+		"String":      {value: "", want: valueKindString},
+		"Int":         {value: int32(0), want: valueKindInteger},
+		"Uint":        {value: uint8(0), want: valueKindInteger},
+		"Float":       {value: float64(0), want: valueKindNumber},
+		"Bool":        {value: false, want: valueKindBoolean},
+		"Pointer":     {value: new(bool), want: valueKindBoolean},
+		"Struct":      {value: metav1.ObjectMeta{}, want: valueKindObject},
+		"Map":         {value: map[string]string{}, want: valueKindObject},
+		"Slice":       {value: []string{}, want: valueKindArray},
+		"Bytes":       {value: []byte{}, want: valueKindString},
+		"Quantity":    {value: resource.Quantity{}, want: valueKindQuantity},
+		"IntOrString": {value: intstr.IntOrString{}, want: valueKindIntOrString},
+		"Time":        {value: metav1.Time{}, want: valueKindString},
+		"CustomJSON":  {value: runtime.RawExtension{}, want: valueKindAny},
+		"Nil":         {want: valueKindAny},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := kindOf(reflect.TypeOf(tc.value)); got != tc.want {
+				t.Errorf("kindOf(%T) = %s, want %s", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidatePatchTypes(t *testing.T) {
+	convert := func(to xapiextv1.TransformIOType) xapiextv1.Transform {
+		return xapiextv1.Transform{
+			Type:    xapiextv1.TransformTypeConvert,
+			Convert: &xapiextv1.ConvertTransform{ToType: to},
+		}
+	}
+	json := func(raw string) extv1.JSON {
+		return extv1.JSON{Raw: []byte(raw)}
+	}
+	str := func(st xapiextv1.StringTransform) xapiextv1.Transform {
+		return xapiextv1.Transform{Type: xapiextv1.TransformTypeString, String: &st}
+	}
+	strConvert := func(c xapiextv1.StringConversionType) xapiextv1.Transform {
+		return str(xapiextv1.StringTransform{Type: xapiextv1.StringTransformTypeConvert, Convert: &c})
+	}
+
+	cases := map[string]struct {
+		in         valueKind
+		transforms []xapiextv1.Transform
+		field      valueKind
+		wantErr    string
+	}{
+		"SameKind": {
+			in:    valueKindString,
+			field: valueKindString,
+		},
+		"IntegerToNumber": {
+			in:    valueKindInteger,
+			field: valueKindNumber,
+		},
+		"NumberToInteger": {
+			in:      valueKindNumber,
+			field:   valueKindInteger,
+			wantErr: "cannot patch a value of type number into a field of type integer",
+		},
+		"StringToQuantity": {
+			in:    valueKindString,
+			field: valueKindQuantity,
+		},
+		"QuantityReadAsString": {
+			in:      valueKindQuantity,
+			field:   valueKindInteger,
+			wantErr: "cannot patch a value of type string",
+		},
+		"IntOrStringReadAsAny": {
+			in:    valueKindIntOrString,
+			field: valueKindBoolean,
+		},
+		"BooleanToIntOrString": {
+			in:      valueKindBoolean,
+			field:   valueKindIntOrString,
+			wantErr: "cannot patch a value of type boolean",
+		},
+		"ConvertStringToInteger": {
+			in:         valueKindString,
+			transforms: []xapiextv1.Transform{convert(xapiextv1.TransformIOTypeInt64)},
+			field:      valueKindInteger,
+		},
+		"ConvertObjectToString": {
+			in:         valueKindObject,
+			transforms: []xapiextv1.Transform{convert(xapiextv1.TransformIOTypeString)},
+			field:      valueKindString,
+			wantErr:    "transform at index 0 is invalid: conversion from object to string is not supported",
+		},
+		"ConvertIntegerToObject": {
+			in:         valueKindInteger,
+			transforms: []xapiextv1.Transform{convert(xapiextv1.TransformIOTypeObject)},
+			field:      valueKindObject,
+			wantErr:    "conversion from integer to object is not supported",
+		},
+		"ConvertUnknownType": {
+			in:         valueKindString,
+			transforms: []xapiextv1.Transform{convert("complex")},
+			field:      valueKindAny,
+			wantErr:    "unknown convert transform type complex",
+		},
+		"ConvertMissingConfig": {
+			in:         valueKindString,
+			transforms: []xapiextv1.Transform{{Type: xapiextv1.TransformTypeConvert}},
+			field:      valueKindAny,
+			wantErr:    "convert transform requires configuration",
+		},
+		"MapToCommonKind": {
+			in: valueKindString,
+			transforms: []xapiextv1.Transform{{
+				Type: xapiextv1.TransformTypeMap,
+				Map: &xapiextv1.MapTransform{Pairs: map[string]extv1.JSON{
+					"a": json(`1`),
+					"b": json(`2.5`),
+				}},
+			}},
+			field: valueKindNumber,
+		},
+		"MapMixedKinds": {
+			in: valueKindString,
+			transforms: []xapiextv1.Transform{{
+				Type: xapiextv1.TransformTypeMap,
+				Map: &xapiextv1.MapTransform{Pairs: map[string]extv1.JSON{
+					"a": json(`1`),
+					"b": json(`"b"`),
+				}},
+			}},
+			field: valueKindBoolean,
+		},
+		"MapRequiresString": {
+			in: valueKindInteger,
+			transforms: []xapiextv1.Transform{{
+				Type: xapiextv1.TransformTypeMap,
+				Map:  &xapiextv1.MapTransform{},
+			}},
+			field:   valueKindAny,
+			wantErr: "map transform requires an input of type string but got integer",
+		},
+		"MatchFallbackToInput": {
+			in: valueKindString,
+			transforms: []xapiextv1.Transform{{
+				Type: xapiextv1.TransformTypeMatch,
+				Match: &xapiextv1.MatchTransform{
+					Patterns:   []xapiextv1.MatchTransformPattern{{Result: json(`"x"`)}},
+					FallbackTo: xapiextv1.MatchFallbackToTypeInput,
+				},
+			}},
+			field: valueKindString,
+		},
+		"MatchFallbackValue": {
+			in: valueKindString,
+			transforms: []xapiextv1.Transform{{
+				Type: xapiextv1.TransformTypeMatch,
+				Match: &xapiextv1.MatchTransform{
+					Patterns:      []xapiextv1.MatchTransformPattern{{Result: json(`true`)}},
+					FallbackValue: json(`false`),
+				},
+			}},
+			field: valueKindBoolean,
+		},
+		"MathRequiresNumber": {
+			in:         valueKindString,
+			transforms: []xapiextv1.Transform{{Type: xapiextv1.TransformTypeMath}},
+			field:      valueKindAny,
+			wantErr:    "math transform requires an input of type number but got string",
+		},
+		"StringProducesString": {
+			in:         valueKindInteger,
+			transforms: []xapiextv1.Transform{str(xapiextv1.StringTransform{Type: xapiextv1.StringTransformTypeFormat, Format: ptr("%d")})},
+			field:      valueKindString,
+		},
+		"StringFormatRequiresScalar": {
+			in:         valueKindObject,
+			transforms: []xapiextv1.Transform{str(xapiextv1.StringTransform{Type: xapiextv1.StringTransformTypeFormat, Format: ptr("%v")})},
+			field:      valueKindString,
+			wantErr:    "string Format transform requires a scalar input but got object",
+		},
+		"StringFormatRequiresConfig": {
+			in:         valueKindString,
+			transforms: []xapiextv1.Transform{str(xapiextv1.StringTransform{Type: xapiextv1.StringTransformTypeFormat})},
+			field:      valueKindString,
+			wantErr:    "string Format transform requires configuration",
+		},
+		"StringJoin": {
+			in:         valueKindArray,
+			transforms: []xapiextv1.Transform{str(xapiextv1.StringTransform{Type: xapiextv1.StringTransformTypeJoin, Join: &xapiextv1.StringTransformJoin{Separator: ","}})},
+			field:      valueKindString,
+		},
+		"StringJoinRequiresArray": {
+			in:         valueKindString,
+			transforms: []xapiextv1.Transform{str(xapiextv1.StringTransform{Type: xapiextv1.StringTransformTypeJoin, Join: &xapiextv1.StringTransformJoin{Separator: ","}})},
+			field:      valueKindString,
+			wantErr:    "string Join transform requires an input of type array but got string",
+		},
+		"StringTrimPrefixRequiresString": {
+			in:         valueKindInteger,
+			transforms: []xapiextv1.Transform{str(xapiextv1.StringTransform{Type: xapiextv1.StringTransformTypeTrimPrefix, Trim: ptr("a")})},
+			field:      valueKindString,
+			wantErr:    "string TrimPrefix transform requires an input of type string but got integer",
+		},
+		"StringTrimSuffixOfAny": {
+			in:         valueKindAny,
+			transforms: []xapiextv1.Transform{str(xapiextv1.StringTransform{Type: xapiextv1.StringTransformTypeTrimSuffix, Trim: ptr("a")})},
+			field:      valueKindString,
+		},
+		"StringTrimRequiresConfig": {
+			in:         valueKindString,
+			transforms: []xapiextv1.Transform{str(xapiextv1.StringTransform{Type: xapiextv1.StringTransformTypeTrimSuffix})},
+			field:      valueKindString,
+			wantErr:    "string TrimSuffix transform requires configuration",
+		},
+		"StringRegexpRequiresString": {
+			in:         valueKindBoolean,
+			transforms: []xapiextv1.Transform{str(xapiextv1.StringTransform{Type: xapiextv1.StringTransformTypeRegexp, Regexp: &xapiextv1.StringTransformRegexp{Match: "a"}})},
+			field:      valueKindString,
+			wantErr:    "string Regexp transform requires an input of type string but got boolean",
+		},
+		"StringToUpperRequiresString": {
+			in:         valueKindInteger,
+			transforms: []xapiextv1.Transform{strConvert(xapiextv1.StringConversionTypeToUpper)},
+			field:      valueKindString,
+			wantErr:    "string ToUpper transform requires an input of type string but got integer",
+		},
+		"StringFromBase64RequiresString": {
+			in:         valueKindArray,
+			transforms: []xapiextv1.Transform{strConvert(xapiextv1.StringConversionTypeFromBase64)},
+			field:      valueKindString,
+			wantErr:    "string FromBase64 transform requires an input of type string but got array",
+		},
+		"StringToJSONOfObject": {
+			in:         valueKindObject,
+			transforms: []xapiextv1.Transform{strConvert(xapiextv1.StringConversionTypeToJSON)},
+			field:      valueKindString,
+		},
+		"StringToSHA256OfArray": {
+			in:         valueKindArray,
+			transforms: []xapiextv1.Transform{strConvert(xapiextv1.StringConversionTypeToSHA256)},
+			field:      valueKindString,
+		},
+		"StringConvertRequiresConfig": {
+			in:         valueKindString,
+			transforms: []xapiextv1.Transform{str(xapiextv1.StringTransform{Type: xapiextv1.StringTransformTypeConvert})},
+			field:      valueKindString,
+			wantErr:    "string Convert transform requires configuration",
+		},
+		"UnknownStringConversion": {
+			in:         valueKindString,
+			transforms: []xapiextv1.Transform{strConvert("ToTitle")},
+			field:      valueKindString,
+			wantErr:    "unknown string conversion type ToTitle",
+		},
+		"UnknownStringTransform": {
+			in:         valueKindString,
+			transforms: []xapiextv1.Transform{str(xapiextv1.StringTransform{Type: "Replace"})},
+			field:      valueKindString,
+			wantErr:    "unknown string transform type Replace",
+		},
+		"StringAfterConvert": {
+			in: valueKindInteger,
+			transforms: []xapiextv1.Transform{
+				convert(xapiextv1.TransformIOTypeString),
+				str(xapiextv1.StringTransform{Type: xapiextv1.StringTransformTypeTrimPrefix, Trim: ptr("0")}),
+			},
+			field: valueKindString,
+		},
+		"Chain": {
+			in: valueKindString,
+			transforms: []xapiextv1.Transform{
+				convert(xapiextv1.TransformIOTypeInt64),
+				{Type: xapiextv1.TransformTypeMath},
+				convert(xapiextv1.TransformIOTypeString),
+			},
+			field: valueKindString,
+		},
+		"UnknownTransform": {
+			in:         valueKindString,
+			transforms: []xapiextv1.Transform{{Type: "nope"}},
+			field:      valueKindAny,
+			wantErr:    "transform at index 0 is invalid: unknown transform type nope",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validatePatchTypes(tc.in, tc.transforms, tc.field)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("validatePatchTypes(): %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("validatePatchTypes() = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
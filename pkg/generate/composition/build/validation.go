@@ -0,0 +1,162 @@
+package build
+
+import (
+	"strings"
+
+	xgt "github.com/crossplane-contrib/function-go-templating/input/v1beta1"
+	xpt "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	xkcl "github.com/mproffitt/crossbuilder/pkg/generate/composition/input/kcl/v1alpha1"
+)
+
+const (
+	errEmptyPipeline              = "pipeline must contain at least one step"
+	errResourcesInPipelineMode    = "resources cannot be used in pipeline mode, use a patch-and-transform step instead"
+	errEmptyStepName              = "step name must not be empty"
+	errFmtInvalidStepName         = "step name %q is not a valid DNS label: %s"
+	errFmtDuplicateStepName       = "step name %q is already used by step at index %d"
+	errMissingFunctionRef         = "no function reference set, use WithFunctionRef or WithFunction"
+	errEmptyFunctionRefName       = "function reference name must not be empty"
+	errFmtInvalidFunctionRefName  = "function reference name %q is invalid: %s"
+	errInvalidInput               = "invalid input"
+	errFmtInputMissingType        = "input of type %T has no apiVersion or kind"
+	errFmtUnknownTemplateSource   = "unknown template source %q"
+	errInlineTemplateEmpty        = "inline template must not be empty"
+	errFileSystemDirPathEmpty     = "fileSystem.dirPath must not be empty"
+	errNoKCLSource                = "spec.source must not be empty"
+	errNoResources                = "at least one resource is required"
+	errFmtEmptyResourceName       = "name of resource at index %d must not be empty"
+	errFmtDuplicateResourceName   = "resource name %q is already used by resource at index %d"
+	errFmtInvalidCredentials      = "invalid credentials at index %d"
+	errEmptyCredentialsName       = "credentials name must not be empty"
+	errFmtDuplicateCredentials    = "credentials name %q is already used by credentials at index %d"
+	errFmtUnknownCredentialSource = "unknown credentials source %q"
+	errMissingSecretRef           = "secretRef is required for source Secret"
+	errEmptySecretRefName         = "secretRef.name must not be empty"
+	errEmptySecretRefNamespace    = "secretRef.namespace must not be empty"
+	errUnexpectedSecretRef        = "secretRef must not be set for source None"
+)
+
+// validatePipelineStep checks the given step is accepted by Crossplane.
+func validatePipelineStep(step xapiextv1.PipelineStep) error {
+	if step.Step == "" {
+		return errors.New(errEmptyStepName)
+	}
+	if errs := validation.IsDNS1123Label(step.Step); len(errs) > 0 {
+		return errors.Errorf(errFmtInvalidStepName, step.Step, strings.Join(errs, ", "))
+	}
+
+	if step.FunctionRef.Name == "" {
+		return errors.New(errEmptyFunctionRefName)
+	}
+	if errs := validation.IsDNS1123Subdomain(step.FunctionRef.Name); len(errs) > 0 {
+		return errors.Errorf(errFmtInvalidFunctionRefName, step.FunctionRef.Name, strings.Join(errs, ", "))
+	}
+
+	seen := make(map[string]int, len(step.Credentials))
+	for i, c := range step.Credentials {
+		if j, ok := seen[c.Name]; ok {
+			return errors.Wrapf(errors.Errorf(errFmtDuplicateCredentials, c.Name, j), errFmtInvalidCredentials, i)
+		}
+		seen[c.Name] = i
+
+		if err := validateCredentials(c); err != nil {
+			return errors.Wrapf(err, errFmtInvalidCredentials, i)
+		}
+	}
+
+	if step.Input != nil {
+		return errors.Wrap(validateInput(step.Input.Object), errInvalidInput)
+	}
+	return nil
+}
+
+func validateCredentials(c xapiextv1.FunctionCredentials) error {
+	if c.Name == "" {
+		return errors.New(errEmptyCredentialsName)
+	}
+
+	switch c.Source {
+	case xapiextv1.FunctionCredentialsSourceSecret:
+		if c.SecretRef == nil {
+			return errors.New(errMissingSecretRef)
+		}
+		if c.SecretRef.Name == "" {
+			return errors.New(errEmptySecretRefName)
+		}
+		if c.SecretRef.Namespace == "" {
+			return errors.New(errEmptySecretRefNamespace)
+		}
+	case xapiextv1.FunctionCredentialsSourceNone:
+		if c.SecretRef != nil {
+			return errors.New(errUnexpectedSecretRef)
+		}
+	default:
+		return errors.Errorf(errFmtUnknownCredentialSource, c.Source)
+	}
+	return nil
+}
+
+// validateInput checks the type information of the given input and runs
+// additional checks for the inputs of well known functions.
+func validateInput(input runtime.Object) error {
+	if input == nil {
+		return errors.New(errNilObject)
+	}
+	if gvk := input.GetObjectKind().GroupVersionKind(); gvk.Version == "" || gvk.Kind == "" {
+		return errors.Errorf(errFmtInputMissingType, input)
+	}
+
+	switch in := input.(type) {
+	case *xgt.GoTemplate:
+		return validateGoTemplate(in)
+	case *xpt.Resources:
+		return validateResources(in)
+	case *xkcl.KCLInput:
+		if strings.TrimSpace(in.Spec.Source) == "" {
+			return errors.New(errNoKCLSource)
+		}
+	}
+	return nil
+}
+
+func validateGoTemplate(in *xgt.GoTemplate) error {
+	switch in.Source {
+	case xgt.InlineSource:
+		if in.Inline == nil || strings.TrimSpace(in.Inline.Template) == "" {
+			return errors.New(errInlineTemplateEmpty)
+		}
+		if _, err := parseGoTemplate(in.Inline.Template, in.Delims); err != nil {
+			return errors.Wrap(err, errParseGoTemplate)
+		}
+	case xgt.FileSystemSource:
+		if in.FileSystem == nil || in.FileSystem.DirPath == "" {
+			return errors.New(errFileSystemDirPathEmpty)
+		}
+	default:
+		return errors.Errorf(errFmtUnknownTemplateSource, in.Source)
+	}
+	return nil
+}
+
+func validateResources(in *xpt.Resources) error {
+	if len(in.Resources) == 0 && in.Environment == nil {
+		return errors.New(errNoResources)
+	}
+
+	seen := make(map[string]int, len(in.Resources))
+	for i, r := range in.Resources {
+		if r.Name == "" {
+			return errors.Errorf(errFmtEmptyResourceName, i)
+		}
+		if j, ok := seen[r.Name]; ok {
+			return errors.Errorf(errFmtDuplicateResourceName, r.Name, j)
+		}
+		seen[r.Name] = i
+	}
+	return nil
+}
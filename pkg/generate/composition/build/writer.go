@@ -1,14 +1,18 @@
 package build
 
 import (
-	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"text/template"
 
+	sprig "github.com/Masterminds/sprig/v3"
 	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/yaml"
 )
 
@@ -19,8 +23,19 @@ type CompositionWriter interface {
 	Write(c xapiextv1.Composition) error
 }
 
+// documentSeparator separates the documents of a YAML stream.
+const documentSeparator = "---\n"
+
+// flusher is implemented by CompositionWriters that buffer compositions until
+// all of them have been written.
+type flusher interface {
+	// Flush writes the buffered compositions.
+	Flush() error
+}
+
 // NewWriterWriter creates a CompositionWriter that writes to the given
-// io.Writer.
+// io.Writer. Compositions are written as separate documents of a YAML stream
+// in the order they are built.
 func NewWriterWriter(w io.Writer) CompositionWriter {
 	return &writerWriter{
 		writer: w,
@@ -28,7 +43,8 @@ func NewWriterWriter(w io.Writer) CompositionWriter {
 }
 
 type writerWriter struct {
-	writer io.Writer
+	writer  io.Writer
+	written bool
 }
 
 func (w *writerWriter) Write(c xapiextv1.Composition) error {
@@ -36,35 +52,288 @@ func (w *writerWriter) Write(c xapiextv1.Composition) error {
 	if err != nil {
 		return err
 	}
-	_, err = w.writer.Write(b)
-	return err
+	if w.written {
+		b = append([]byte(documentSeparator), b...)
+	}
+	if _, err = w.writer.Write(b); err != nil {
+		return err
+	}
+	w.written = true
+	return nil
 }
 
-// NewDirectoryWriter creates a new CompositionWriter that writes each
-// composition to the given directory using the objects name as filename.
-func NewDirectoryWriter(dir string) CompositionWriter {
-	return &directoryWriter{
-		dir: dir,
+// StreamWriter is a CompositionWriter that writes compositions, and the XRDs
+// defining their composites, as a single YAML stream.
+type StreamWriter interface {
+	CompositionWriter
+
+	// WriteXRD adds the given XRD to the stream.
+	WriteXRD(xrd xapiextv1.CompositeResourceDefinition) error
+
+	// Flush writes all documents added so far, sorted by composite group
+	// and kind, each XRD followed by the compositions of its composite,
+	// sorted by version and name. The Runner flushes the writer once all
+	// compositions are written.
+	Flush() error
+}
+
+// NewStreamWriter creates a StreamWriter writing to the given io.Writer.
+func NewStreamWriter(w io.Writer) StreamWriter {
+	return &streamWriter{
+		writer: w,
 	}
 }
 
-type directoryWriter struct {
-	dir string
+type streamWriter struct {
+	writer    io.Writer
+	documents []streamDocument
+	written   bool
 }
 
-func (w *directoryWriter) Write(c xapiextv1.Composition) error {
-	p := strings.Split(c.Spec.CompositeTypeRef.APIVersion, ".")[0]
+// streamDocument is a document of a YAML stream and the key it is sorted by.
+type streamDocument struct {
+	group, kind, version, name string
+	xrd                        bool
+	content                    []byte
+}
 
-	dir := filepath.Join(w.dir, p)
+func (d streamDocument) less(o streamDocument) bool {
+	switch {
+	case d.group != o.group:
+		return d.group < o.group
+	case d.kind != o.kind:
+		return d.kind < o.kind
+	case d.xrd != o.xrd:
+		return d.xrd
+	case d.version != o.version:
+		return d.version < o.version
+	}
+	return d.name < o.name
+}
 
+func (w *streamWriter) Write(c xapiextv1.Composition) error {
 	b, err := yaml.Marshal(c)
 	if err != nil {
 		return err
 	}
-	if err := os.MkdirAll(dir, fs.FileMode(0777)); err != nil {
+	gv, err := schema.ParseGroupVersion(c.Spec.CompositeTypeRef.APIVersion)
+	if err != nil {
+		return err
+	}
+	w.documents = append(w.documents, streamDocument{
+		group:   gv.Group,
+		kind:    c.Spec.CompositeTypeRef.Kind,
+		version: gv.Version,
+		name:    c.GetName(),
+		content: b,
+	})
+	return nil
+}
+
+func (w *streamWriter) WriteXRD(xrd xapiextv1.CompositeResourceDefinition) error {
+	xrd.SetGroupVersionKind(xapiextv1.CompositeResourceDefinitionGroupVersionKind)
+	b, err := yaml.Marshal(xrd)
+	if err != nil {
 		return err
 	}
+	w.documents = append(w.documents, streamDocument{
+		group:   xrd.Spec.Group,
+		kind:    xrd.Spec.Names.Kind,
+		name:    xrd.GetName(),
+		xrd:     true,
+		content: b,
+	})
+	return nil
+}
+
+func (w *streamWriter) Flush() error {
+	sort.SliceStable(w.documents, func(i, j int) bool {
+		return w.documents[i].less(w.documents[j])
+	})
+	for _, d := range w.documents {
+		if w.written {
+			if _, err := io.WriteString(w.writer, documentSeparator); err != nil {
+				return err
+			}
+		}
+		if _, err := w.writer.Write(d.content); err != nil {
+			return err
+		}
+		w.written = true
+	}
+	w.documents = nil
+	return nil
+}
+
+const (
+	errParseLayout          = "cannot parse output layout"
+	errFmtExecuteLayout     = "cannot render output path of composition %q"
+	errFmtInvalidOutputPath = "output path %q of composition %q is not within the output directory"
+
+	// DefaultLayout is the default layout of a DirectoryWriter. Compositions
+	// are written to a directory named after the first label of the group
+	// of their composite.
+	DefaultLayout = "{{ .GroupPrefix }}/{{ .Name }}.yaml"
+
+	// DefaultFileMode is the default mode of the files written by a
+	// DirectoryWriter.
+	DefaultFileMode fs.FileMode = 0664
+
+	// DefaultDirMode is the default mode of the directories created by a
+	// DirectoryWriter.
+	DefaultDirMode fs.FileMode = 0777
+)
+
+// DirectoryWriterConfig specifies the config of a DirectoryWriter.
+type DirectoryWriterConfig struct {
+	// Dir is the directory compositions are written to.
+	Dir string
+
+	// Layout is a Go template rendering the path, relative to Dir, a
+	// composition is written to. It is executed with the LayoutData of the
+	// composition and supports the sprig functions. Defaults to
+	// DefaultLayout.
+	Layout string
+
+	// FileMode is the mode of the files written. Defaults to DefaultFileMode.
+	FileMode fs.FileMode
+
+	// DirMode is the mode of the directories created. Defaults to
+	// DefaultDirMode.
+	DirMode fs.FileMode
+}
+
+// LayoutData is the data the layout of a DirectoryWriter is rendered with.
+type LayoutData struct {
+	// Group is the group of the composite, for example
+	// xexample.crossplane.example.io.
+	Group string
+
+	// GroupPrefix is the first label of the group, for example xexample.
+	GroupPrefix string
+
+	// Version is the version of the composite.
+	Version string
+
+	// Kind is the kind of the composite.
+	Kind string
+
+	// Name is the name of the composition.
+	Name string
+
+	// Labels are the labels of the composition.
+	Labels map[string]string
+}
 
-	filename := fmt.Sprintf("%s.yaml", c.GetName())
-	return os.WriteFile(filepath.Join(dir, filename), b, fs.FileMode(0664))
+// DirectoryWriter is a CompositionWriter that writes each composition to its
+// own file in a directory.
+type DirectoryWriter interface {
+	CompositionWriter
+
+	// Prune deletes the files this writer generated before, as recorded in
+	// the manifest of the directory, that were not written again, and
+	// records the files written in the manifest. Files missing from the
+	// manifest are never deleted. With dryRun set nothing is deleted or
+	// recorded. The paths of the stale files are returned.
+	Prune(dryRun bool) ([]string, error)
+}
+
+// NewDirectoryWriter creates a new DirectoryWriter that writes each
+// composition to the given directory using the default layout.
+func NewDirectoryWriter(dir string) DirectoryWriter {
+	w, err := newDirectoryWriter(DirectoryWriterConfig{Dir: dir})
+	if err != nil {
+		panic(err) // the default layout always parses
+	}
+	return w
+}
+
+// NewDirectoryWriterFromConfig creates a new DirectoryWriter that writes each
+// composition to the path rendered from the configured layout.
+func NewDirectoryWriterFromConfig(config DirectoryWriterConfig) (DirectoryWriter, error) {
+	return newDirectoryWriter(config)
+}
+
+func newDirectoryWriter(config DirectoryWriterConfig) (*directoryWriter, error) {
+	if config.Layout == "" {
+		config.Layout = DefaultLayout
+	}
+	if config.FileMode == 0 {
+		config.FileMode = DefaultFileMode
+	}
+	if config.DirMode == 0 {
+		config.DirMode = DefaultDirMode
+	}
+	layout, err := template.New("layout").Funcs(sprig.TxtFuncMap()).Option("missingkey=error").Parse(config.Layout)
+	if err != nil {
+		return nil, errors.Wrap(err, errParseLayout)
+	}
+	return &directoryWriter{
+		dir:      filepath.Clean(config.Dir),
+		layout:   layout,
+		fileMode: config.FileMode,
+		dirMode:  config.DirMode,
+		written:  make(map[string]bool),
+	}, nil
+}
+
+type directoryWriter struct {
+	dir      string
+	layout   *template.Template
+	fileMode fs.FileMode
+	dirMode  fs.FileMode
+	written  map[string]bool
+}
+
+func (w *directoryWriter) Write(c xapiextv1.Composition) error {
+	path, b, err := w.render(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), w.dirMode); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, b, w.fileMode); err != nil {
+		return err
+	}
+	w.written[path] = true
+	return nil
+}
+
+// render returns the path the given composition is written to and its
+// content.
+func (w *directoryWriter) render(c xapiextv1.Composition) (string, []byte, error) {
+	path, err := w.outputPath(c)
+	if err != nil {
+		return "", nil, err
+	}
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, b, nil
+}
+
+func (w *directoryWriter) outputPath(c xapiextv1.Composition) (string, error) {
+	gv, err := schema.ParseGroupVersion(c.Spec.CompositeTypeRef.APIVersion)
+	if err != nil {
+		return "", err
+	}
+	data := LayoutData{
+		Group:       gv.Group,
+		GroupPrefix: strings.Split(gv.Group, ".")[0],
+		Version:     gv.Version,
+		Kind:        c.Spec.CompositeTypeRef.Kind,
+		Name:        c.GetName(),
+		Labels:      c.GetLabels(),
+	}
+
+	path := &strings.Builder{}
+	if err := w.layout.Execute(path, data); err != nil {
+		return "", errors.Wrapf(err, errFmtExecuteLayout, c.GetName())
+	}
+	if !filepath.IsLocal(path.String()) {
+		return "", errors.Errorf(errFmtInvalidOutputPath, path.String(), c.GetName())
+	}
+	return filepath.Join(w.dir, path.String()), nil
 }
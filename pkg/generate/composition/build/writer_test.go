@@ -0,0 +1,265 @@
+package build
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// testComposition returns a composition with the given name composing the
+// given composite type.
+func testComposition(name, apiVersion, kind string) xapiextv1.Composition {
+	return xapiextv1.Composition{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: xapiextv1.CompositionSpec{
+			CompositeTypeRef: xapiextv1.TypeReference{APIVersion: apiVersion, Kind: kind},
+		},
+	}
+}
+
+// documentNames returns the kind and name of each document of the given
+// YAML stream and fails if the stream does not consist of documents
+// separated by a single separator.
+func documentNames(t *testing.T, stream string) []string {
+	t.Helper()
+	if stream == "" {
+		return nil
+	}
+	var names []string
+	for _, doc := range strings.Split(stream, documentSeparator) {
+		obj := struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+		}{}
+		if strings.TrimSpace(doc) == "" {
+			t.Fatalf("stream holds an empty document:\n%s", stream)
+		}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, obj.Kind+"/"+obj.Metadata.Name)
+	}
+	return names
+}
+
+func TestWriterWriter(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewWriterWriter(b)
+	for _, c := range []xapiextv1.Composition{
+		testComposition("b", "b.example.org/v1", "XB"),
+		testComposition("a", "a.example.org/v1", "XA"),
+	} {
+		c.SetGroupVersionKind(xapiextv1.CompositionGroupVersionKind)
+		if err := w.Write(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"Composition/b", "Composition/a"}
+	if got := documentNames(t, b.String()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Write() wrote %q, want %q in the order written", got, want)
+	}
+}
+
+func TestStreamWriter(t *testing.T) {
+	xrd := func(name, group, kind string) xapiextv1.CompositeResourceDefinition {
+		return xapiextv1.CompositeResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: xapiextv1.CompositeResourceDefinitionSpec{
+				Group: group,
+				Names: extv1.CustomResourceDefinitionNames{Kind: kind},
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		xrds         []xapiextv1.CompositeResourceDefinition
+		compositions []xapiextv1.Composition
+		want         []string
+	}{
+		"Empty": {},
+		"SingleComposition": {
+			compositions: []xapiextv1.Composition{testComposition("a", "a.example.org/v1", "XA")},
+			want:         []string{"Composition/a"},
+		},
+		"Sorted": {
+			xrds: []xapiextv1.CompositeResourceDefinition{
+				xrd("xbs.b.example.org", "b.example.org", "XB"),
+				xrd("xas.a.example.org", "a.example.org", "XA"),
+			},
+			compositions: []xapiextv1.Composition{
+				testComposition("z", "b.example.org/v1", "XB"),
+				testComposition("y", "a.example.org/v2", "XA"),
+				testComposition("x", "a.example.org/v1", "XA"),
+				testComposition("c", "a.example.org/v1", "XC"),
+				testComposition("w", "a.example.org/v1", "XA"),
+			},
+			want: []string{
+				"CompositeResourceDefinition/xas.a.example.org",
+				"Composition/w",
+				"Composition/x",
+				"Composition/y",
+				"Composition/c",
+				"CompositeResourceDefinition/xbs.b.example.org",
+				"Composition/z",
+			},
+		},
+		"XRDWithoutCompositions": {
+			xrds: []xapiextv1.CompositeResourceDefinition{xrd("xas.a.example.org", "a.example.org", "XA")},
+			want: []string{"CompositeResourceDefinition/xas.a.example.org"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			w := NewStreamWriter(b)
+			for _, c := range tc.compositions {
+				c.SetGroupVersionKind(xapiextv1.CompositionGroupVersionKind)
+				if err := w.Write(c); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, x := range tc.xrds {
+				if err := w.WriteXRD(x); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if b.Len() != 0 {
+				t.Errorf("Write() wrote before Flush():\n%s", b.String())
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := documentNames(t, b.String()); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Flush() wrote %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStreamWriterFlushTwice(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewStreamWriter(b)
+	for _, name := range []string{"b", "a"} {
+		c := testComposition(name, "a.example.org/v1", "XA")
+		c.SetGroupVersionKind(xapiextv1.CompositionGroupVersionKind)
+		if err := w.Write(c); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"Composition/b", "Composition/a"}
+	if got := documentNames(t, b.String()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Flush() wrote %q, want %q", got, want)
+	}
+}
+
+func TestDirectoryWriterOutputPath(t *testing.T) {
+	c := testComposition("example", "xexample.crossplane.example.io/v1alpha1", "XExample")
+	c.SetLabels(map[string]string{"provider": "aws"})
+
+	cases := map[string]struct {
+		layout  string
+		want    string
+		wantErr string
+	}{
+		"Default": {
+			want: "xexample/example.yaml",
+		},
+		"Fields": {
+			layout: "{{ .Group }}/{{ .Version }}/{{ .Kind }}/{{ .Name }}.yaml",
+			want:   "xexample.crossplane.example.io/v1alpha1/XExample/example.yaml",
+		},
+		"LabelsAndSprig": {
+			layout: `{{ index .Labels "provider" }}/{{ .Kind | lower }}-{{ .Name }}.yaml`,
+			want:   "aws/xexample-example.yaml",
+		},
+		"MissingField": {
+			layout:  "{{ .Namespace }}/{{ .Name }}.yaml",
+			wantErr: `cannot render output path of composition "example"`,
+		},
+		"Absolute": {
+			layout:  "/etc/{{ .Name }}.yaml",
+			wantErr: `output path "/etc/example.yaml" of composition "example" is not within the output directory`,
+		},
+		"ParentDirectory": {
+			layout:  "../{{ .Name }}.yaml",
+			wantErr: `output path "../example.yaml" of composition "example" is not within the output directory`,
+		},
+		"Cleaned": {
+			layout: "{{ .GroupPrefix }}/../{{ .Name }}.yaml",
+			want:   "example.yaml",
+		},
+		"Empty": {
+			layout:  `{{ "" }}`,
+			wantErr: "is not within the output directory",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := newDirectoryWriter(DirectoryWriterConfig{Dir: dir, Layout: tc.layout})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := w.outputPath(c)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("outputPath(): %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("outputPath() = %q, %v, want error containing %q", got, err, tc.wantErr)
+			case tc.wantErr == "" && got != filepath.Join(dir, tc.want):
+				t.Errorf("outputPath() = %q, want %q", got, filepath.Join(dir, tc.want))
+			}
+		})
+	}
+}
+
+func TestNewDirectoryWriterFromConfig(t *testing.T) {
+	if _, err := NewDirectoryWriterFromConfig(DirectoryWriterConfig{Layout: "{{ .Name"}); err == nil || !strings.Contains(err.Error(), errParseLayout) {
+		t.Errorf("NewDirectoryWriterFromConfig() = %v, want error containing %q", err, errParseLayout)
+	}
+
+	dir := t.TempDir()
+	w, err := NewDirectoryWriterFromConfig(DirectoryWriterConfig{
+		Dir:      dir,
+		Layout:   "{{ .GroupPrefix }}/{{ .Version }}/{{ .Name }}.yaml",
+		FileMode: 0o600,
+		DirMode:  0o700,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(testComposition("example", "xexample.crossplane.example.io/v1alpha1", "XExample")); err != nil {
+		t.Fatalf("Write(): %v", err)
+	}
+
+	for path, want := range map[string]fs.FileMode{
+		"xexample":                       fs.ModeDir | 0o700,
+		"xexample/v1alpha1":              fs.ModeDir | 0o700,
+		"xexample/v1alpha1/example.yaml": 0o600,
+	} {
+		info, err := os.Stat(filepath.Join(dir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode() != want {
+			t.Errorf("mode of %s = %s, want %s", path, info.Mode(), want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package build
+
+import (
+	"encoding/json"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	errDecodeXRD           = "cannot decode composite resource definition"
+	errFmtDuplicateXRDFile = "composite resource definition %q is defined in both %s and %s"
+)
+
+// LoadCompositeResourceDefinitions reads all CompositeResourceDefinitions
+// from the YAML files in the given directory and its subdirectories. Other
+// objects and documents that are not objects are ignored, as is a directory
+// that does not exist. XRDs with the same name in different files are an
+// error.
+func LoadCompositeResourceDefinitions(dir string) ([]xapiextv1.CompositeResourceDefinition, error) {
+	var xrds []xapiextv1.CompositeResourceDefinition
+	files := make(map[string]string)
+	err := walkManifests(dir, func(path string, raw json.RawMessage, gvk schema.GroupVersionKind) error {
+		if gvk != xapiextv1.CompositeResourceDefinitionGroupVersionKind {
+			return nil
+		}
+		xrd := xapiextv1.CompositeResourceDefinition{}
+		if err := json.Unmarshal(raw, &xrd); err != nil {
+			return errors.Wrap(err, errDecodeXRD)
+		}
+		if other, ok := files[xrd.GetName()]; ok {
+			return errors.Errorf(errFmtDuplicateXRDFile, xrd.GetName(), other, path)
+		}
+		files[xrd.GetName()] = path
+		xrds = append(xrds, xrd)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return xrds, nil
+}
+
+// compositeResourceDefinitionFor returns the XRD defining the given
+// composite type or nil if there is none.
+func compositeResourceDefinitionFor(xrds []xapiextv1.CompositeResourceDefinition, gvk schema.GroupVersionKind) *xapiextv1.CompositeResourceDefinition {
+	for i := range xrds {
+		if xrds[i].Spec.Group == gvk.Group && xrds[i].Spec.Names.Kind == gvk.Kind {
+			return &xrds[i]
+		}
+	}
+	return nil
+}
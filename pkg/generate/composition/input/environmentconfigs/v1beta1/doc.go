@@ -0,0 +1,6 @@
+// Package v1beta1 contains the input type accepted by
+// function-environment-configs.
+// +kubebuilder:object:generate=true
+// +groupName=environmentconfigs.fn.crossplane.io
+// +versionName=v1beta1
+package v1beta1
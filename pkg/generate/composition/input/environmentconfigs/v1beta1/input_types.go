@@ -0,0 +1,40 @@
+package v1beta1
+
+import (
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// InputSpec selects the EnvironmentConfigs merged into the environment.
+type InputSpec struct {
+	// Policy controls whether the selected EnvironmentConfigs are required.
+	Policy *xpv1.Policy `json:"policy,omitempty"`
+
+	// DefaultData is merged into the environment before any selected
+	// EnvironmentConfig.
+	DefaultData map[string]extv1.JSON `json:"defaultData,omitempty"`
+
+	// EnvironmentConfigs selects the EnvironmentConfigs to merge, in order.
+	EnvironmentConfigs []xapiextv1.EnvironmentSource `json:"environmentConfigs,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// Input is the input for function-environment-configs.
+type Input struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec InputSpec `json:"spec"`
+}
+
+var (
+	InputKind             = "Input"
+	InputGroupVersionKind = GroupVersion.WithKind(InputKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&Input{})
+}
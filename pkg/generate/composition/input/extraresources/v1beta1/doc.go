@@ -0,0 +1,6 @@
+// Package v1beta1 contains the input type accepted by
+// function-extra-resources.
+// +kubebuilder:object:generate=true
+// +groupName=extra-resources.fn.crossplane.io
+// +versionName=v1beta1
+package v1beta1
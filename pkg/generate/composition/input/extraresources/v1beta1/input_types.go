@@ -0,0 +1,89 @@
+package v1beta1
+
+import (
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ResourceSourceType specifies the way the extra resources are selected.
+type ResourceSourceType string
+
+const (
+	// ResourceSourceTypeReference selects a single resource by name.
+	ResourceSourceTypeReference ResourceSourceType = "Reference"
+
+	// ResourceSourceTypeSelector selects resources by labels.
+	ResourceSourceTypeSelector ResourceSourceType = "Selector"
+)
+
+// ResourceSourceReference references a single resource by name.
+type ResourceSourceReference struct {
+	// Name of the referenced resource.
+	Name string `json:"name"`
+}
+
+// ResourceSourceSelector selects resources by labels.
+type ResourceSourceSelector struct {
+	// MaxMatch specifies the number of extracted resources in Multiple mode.
+	MaxMatch *uint64 `json:"maxMatch,omitempty"`
+
+	// MinMatch specifies the required minimum of extracted resources.
+	MinMatch *uint64 `json:"minMatch,omitempty"`
+
+	// SortByFieldPath is the path to the field based on which the list of
+	// resources is alphabetically sorted.
+	SortByFieldPath string `json:"sortByFieldPath,omitempty"`
+
+	// MatchLabels ensures an object with matching labels is selected.
+	MatchLabels []xapiextv1.EnvironmentSourceSelectorLabelMatcher `json:"matchLabels,omitempty"`
+}
+
+// ResourceSource selects one or more resources of a single kind.
+type ResourceSource struct {
+	// Type specifies the way the resources are selected.
+	Type ResourceSourceType `json:"type,omitempty"`
+
+	// Ref is a named reference to a single resource.
+	Ref *ResourceSourceReference `json:"ref,omitempty"`
+
+	// Selector selects resources via labels.
+	Selector *ResourceSourceSelector `json:"selector,omitempty"`
+
+	// APIVersion of the selected resources.
+	APIVersion string `json:"apiVersion"`
+
+	// Kind of the selected resources.
+	Kind string `json:"kind"`
+
+	// Into is the key in the function context the resources are stored in.
+	Into string `json:"into"`
+}
+
+// InputSpec selects the extra resources requested from Crossplane.
+type InputSpec struct {
+	// ExtraResources selects the resources to fetch.
+	ExtraResources []ResourceSource `json:"extraResources,omitempty"`
+
+	// Policy controls whether the selected resources are required.
+	Policy *xpv1.Policy `json:"policy,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// Input is the input for function-extra-resources.
+type Input struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec InputSpec `json:"spec"`
+}
+
+var (
+	InputKind             = "Input"
+	InputGroupVersionKind = GroupVersion.WithKind(InputKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&Input{})
+}
@@ -0,0 +1,5 @@
+// Package v1alpha1 contains the input type accepted by function-kcl.
+// +kubebuilder:object:generate=true
+// +groupName=krm.kcl.dev
+// +versionName=v1alpha1
+package v1alpha1
@@ -0,0 +1,63 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Target determines what function-kcl does with the resources returned by
+// the KCL program.
+type Target string
+
+const (
+	// TargetDefault lets the function decide based on the returned resources.
+	TargetDefault Target = "Default"
+
+	// TargetPatchDesired patches the existing desired composed resources.
+	TargetPatchDesired Target = "PatchDesired"
+
+	// TargetPatchResources patches the resources in the input.
+	TargetPatchResources Target = "PatchResources"
+
+	// TargetResources creates new desired composed resources.
+	TargetResources Target = "Resources"
+
+	// TargetXR patches the composite resource.
+	TargetXR Target = "XR"
+)
+
+// RunSpec defines the KCL program executed by function-kcl.
+type RunSpec struct {
+	// Source is the KCL source. This may be inline KCL code, an OCI
+	// reference (oci://...), a git repository or a file system path.
+	Source string `json:"source"`
+
+	// Dependencies is an optional list of KCL module dependencies in
+	// kcl.mod format.
+	Dependencies string `json:"dependencies,omitempty"`
+
+	// Params are passed to the KCL program as top level arguments.
+	Params map[string]runtime.RawExtension `json:"params,omitempty"`
+
+	// Target determines what is done with the output of the KCL program.
+	Target Target `json:"target,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KCLInput is the input for function-kcl.
+type KCLInput struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RunSpec `json:"spec"`
+}
+
+var (
+	KCLInputKind             = "KCLInput"
+	KCLInputGroupVersionKind = GroupVersion.WithKind(KCLInputKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&KCLInput{})
+}
@@ -46,10 +46,10 @@ func (b *builder) Build(c build.CompositionSkeleton) {
 
 	// Add the auto-ready function at the end
 	// This ensures the XR is marked ready when all
-	//   created MRs are ready
+	//   created MRs are ready. The step stays last even
+	//   if further steps are added after it.
 	c.NewPipelineStep("function-auto-ready").
-		WithFunctionRef(xapiextv1.FunctionReference{
-			Name: "function-auto-ready",
-		})
+		WithFunction(build.AutoReady()).
+		AlwaysLast()
 
 }